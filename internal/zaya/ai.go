@@ -8,6 +8,7 @@ import (
 	"github.com/tmc/langchaingo/llms/mistral"
 	"github.com/tmc/langchaingo/llms/openai"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 	"regexp"
 	"strconv"
 	"strings"
@@ -19,6 +20,7 @@ import (
 type aiChat struct {
 	messages []llms.MessageContent
 	msgLens  []int
+	msgTimes []time.Time
 	curCtx   int
 	maxCtx   int
 	maxHst   int
@@ -31,13 +33,14 @@ func newAiChat(prompt string, nCtx, maxHistory int, log *zap.SugaredLogger) *aiC
 	chat := &aiChat{
 		messages: make([]llms.MessageContent, 0, 3),
 		msgLens:  make([]int, 0, 3),
+		msgTimes: make([]time.Time, 0, 3),
 		maxCtx:   nCtx,
 		maxHst:   maxHistory,
 		lastTime: time.Now(),
 		log:      log,
 	}
 
-	chat.addMessage(llms.ChatMessageTypeSystem, prompt, 4000)
+	chat.addMessage(llms.ChatMessageTypeSystem, prompt, len(prompt))
 
 	return chat
 }
@@ -63,6 +66,7 @@ func (chat *aiChat) addMessage(role llms.ChatMessageType, text string, maxTok in
 
 	msgLen := getMessageLen(text, maxTok)
 	chat.msgLens = append(chat.msgLens, msgLen)
+	chat.msgTimes = append(chat.msgTimes, chat.lastTime)
 	chat.curCtx += msgLen
 
 	if (chat.maxCtx > 0 && chat.curCtx >= chat.maxCtx) ||
@@ -82,28 +86,38 @@ func (chat *aiChat) addBotMessage(text string, maxTok int) {
 func (chat *aiChat) removeLastMessage() {
 	chat.curCtx -= chat.msgLens[len(chat.msgLens)-1]
 	chat.msgLens = chat.msgLens[:len(chat.msgLens)-1]
+	chat.msgTimes = chat.msgTimes[:len(chat.msgTimes)-1]
 	chat.messages = chat.messages[:len(chat.messages)-1]
 }
 
 func (chat *aiChat) cleanHistory() {
 	msgCnt := len(chat.messages) - 1
-	if msgCnt == 0 {
+
+	maxRm := msgCnt
+	for i := msgCnt; i > 0; i-- {
+		if chat.messages[i].Role == llms.ChatMessageTypeHuman {
+			maxRm = i - 1
+			break
+		}
+	}
+	if maxRm == 0 {
 		return
 	}
 
 	rmCnt := 0
-	for rmCnt < msgCnt &&
+	for rmCnt < maxRm &&
 		((chat.maxCtx > 0 && chat.curCtx >= chat.maxCtx) ||
 			(chat.maxHst > 0 && msgCnt-rmCnt > chat.maxHst)) {
 		rmCnt++
 		chat.curCtx -= chat.msgLens[rmCnt]
 	}
-	for (rmCnt == 0 || rmCnt%2 != 0) && rmCnt < msgCnt {
+	for (rmCnt == 0 || rmCnt%2 != 0) && rmCnt < maxRm {
 		rmCnt++
 		chat.curCtx -= chat.msgLens[rmCnt]
 	}
 
 	chat.msgLens = append(chat.msgLens[:1], chat.msgLens[rmCnt+1:]...)
+	chat.msgTimes = append(chat.msgTimes[:1], chat.msgTimes[rmCnt+1:]...)
 	chat.messages = append(chat.messages[:1], chat.messages[rmCnt+1:]...)
 
 	chat.log.Infow("clean history",
@@ -119,18 +133,26 @@ func (chat *aiChat) isExpired(maxDur time.Duration) bool {
 func (chat *aiChat) restart() {
 	chat.curCtx = chat.msgLens[0]
 	chat.msgLens = chat.msgLens[:1]
+	chat.msgTimes = chat.msgTimes[:1]
 	chat.messages = chat.messages[:1]
 }
 
+type ChatKey struct {
+	ChatID   int64
+	ThreadID int
+	RoleID   uint
+}
+
 type AI struct {
 	llm     llms.Model
 	altLlm  llms.Model
 	isAlt   atomic.Bool
-	chats   imcache.Cache[int64, *aiChat]
+	chats   imcache.Cache[ChatKey, *aiChat]
 	opts    []llms.CallOption
 	log     *zap.SugaredLogger
 	maxCtx  int
 	maxTok  int
+	temp    float64
 	maxDur  time.Duration
 	chatExp imcache.Expiration
 }
@@ -141,11 +163,16 @@ func NewAI(cfg AiConfig) (*AI, bool) {
 		log:    zap.L().Named("ai").Sugar(),
 		maxCtx: cfg.NCtx - cfg.MaxTok,
 		maxTok: cfg.MaxTok,
+		temp:   cfg.Temp,
 	}
 
 	ai.maxDur = cfg.ExpTime
 	ai.chatExp = imcache.WithSlidingExpiration(cfg.ChatExp)
 
+	zayaMetrics.chatCacheSizeFn = func() float64 {
+		return float64(ai.chats.Len())
+	}
+
 	ai.log.Infow("creating AI",
 		"provider", cfg.Provider,
 		"base_url", cfg.BaseUrl,
@@ -210,49 +237,59 @@ func (ai *AI) IsAltModel() bool {
 	return ai.isAlt.Load()
 }
 
-func (ai *AI) IsChatStarted(chatID int64) bool {
-	_, exists := ai.chats.Get(chatID)
+func (ai *AI) IsChatStarted(key ChatKey) bool {
+	_, exists := ai.chats.Get(key)
 	return exists
 }
 
-func (ai *AI) createChat(chatID int64, prompt string, maxHistory int) *aiChat {
-	chat := newAiChat(prompt, ai.maxCtx, maxHistory, ai.log)
-	ai.chats.Set(chatID, chat, ai.chatExp)
+func (ai *AI) newChat(prompt string, maxHistory int) *aiChat {
+	return newAiChat(prompt, ai.maxCtx, maxHistory, ai.log)
+}
+
+func (ai *AI) createChat(key ChatKey, prompt string, maxHistory int) *aiChat {
+	chat := ai.newChat(prompt, maxHistory)
+	ai.chats.Set(key, chat, ai.chatExp)
 	return chat
 }
 
-func (ai *AI) StartChat(chatID int64, prompt string, maxHistory int) {
-	ai.createChat(chatID, prompt, maxHistory)
-	ai.log.Infow("chat started", "chat_id", chatID)
+func (ai *AI) StartChat(key ChatKey, prompt string, maxHistory int) {
+	ai.createChat(key, prompt, maxHistory)
+	ai.log.Infow("chat started", "chat_id", key.ChatID, "thread_id", key.ThreadID)
 }
 
-func (ai *AI) generate(chatID int64, chat *aiChat, nTry int) (*llms.ContentResponse, bool) {
+func (ai *AI) generate(ctx context.Context, key ChatKey, chat *aiChat, nTry int, forceAlt bool) (*llms.ContentResponse, bool) {
 	if nTry > 5 {
 		return nil, false
 	}
 
 	llm := ai.llm
-	isAlt := ai.isAlt.Load()
-	if isAlt {
+	isAlt := forceAlt || ai.isAlt.Load()
+	if isAlt && ai.altLlm != nil {
 		llm = ai.altLlm
 	}
 
-	resp, err := llm.GenerateContent(context.Background(), chat.messages, ai.opts...)
+	resp, err := llm.GenerateContent(ctx, chat.messages, ai.opts...)
 	if err == nil {
 		return resp, true
 	}
 
+	if ctx.Err() != nil {
+		return nil, false
+	}
+
 	if strings.Contains(err.Error(), "Service Unavailable") {
 		sec := nTry * 3
 		ai.log.Infow("sleeping", "sec", sec)
 		time.Sleep(time.Duration(sec) * time.Second)
 
-		return ai.generate(chatID, chat, nTry+1)
+		zayaMetrics.genRetries.WithLabelValues("unavailable").Inc()
+
+		return ai.generate(ctx, key, chat, nTry+1, forceAlt)
 	}
 
 	idx := strings.Index(err.Error(), "Please try again in")
 	if idx <= 0 {
-		ai.log.Warnw(err.Error(), "chat_id", chatID)
+		ai.log.Warnw(err.Error(), "chat_id", key.ChatID, "thread_id", key.ThreadID)
 		return nil, false
 	}
 
@@ -262,7 +299,7 @@ func (ai *AI) generate(chatID int64, chat *aiChat, nTry int) (*llms.ContentRespo
 	str = regexp.MustCompile(`\d+`).FindString(str)
 	sec, err := strconv.Atoi(str)
 	if err != nil {
-		ai.log.Warnw(err.Error(), "chat_id", chatID)
+		ai.log.Warnw(err.Error(), "chat_id", key.ChatID, "thread_id", key.ThreadID)
 		return nil, false
 	}
 
@@ -274,13 +311,17 @@ func (ai *AI) generate(chatID int64, chat *aiChat, nTry int) (*llms.ContentRespo
 	} else {
 		ai.log.Infow("switching to alt model", "sec", sec)
 		ai.isAlt.Store(true)
+		zayaMetrics.modelSwitches.WithLabelValues("auxiliary").Inc()
 		time.AfterFunc(dur, func() {
 			ai.log.Infow("switching to main model")
 			ai.isAlt.Store(false)
+			zayaMetrics.modelSwitches.WithLabelValues("primary").Inc()
 		})
 	}
 
-	return ai.generate(chatID, chat, nTry+1)
+	zayaMetrics.genRetries.WithLabelValues("rate_limit").Inc()
+
+	return ai.generate(ctx, key, chat, nTry+1, forceAlt)
 }
 
 type AIReply struct {
@@ -288,17 +329,20 @@ type AIReply struct {
 	AtEnd    bool
 	CtxLen   int
 	ReplyLen int
+	Index    int
 }
 
-func (ai *AI) GetReply(chatID int64, userMsg string, forceKeep bool) (AIReply, bool) {
-	beginTime := time.Now().UnixNano()
-
-	chat, ok := ai.chats.Get(chatID)
+func (ai *AI) GetReply(ctx context.Context, key ChatKey, userMsg string, forceKeep bool) (AIReply, bool) {
+	chat, ok := ai.chats.Get(key)
 	if !ok {
-		ai.log.Warnw("chat is not started", "chat_id", chatID)
+		ai.log.Warnw("chat is not started", "chat_id", key.ChatID, "thread_id", key.ThreadID)
 		return AIReply{}, false
 	}
 
+	return ai.getChatReply(ctx, key, chat, userMsg, forceKeep)
+}
+
+func (ai *AI) getChatReply(ctx context.Context, key ChatKey, chat *aiChat, userMsg string, forceKeep bool) (AIReply, bool) {
 	chat.hstLock.Lock()
 	defer chat.hstLock.Unlock()
 
@@ -306,16 +350,70 @@ func (ai *AI) GetReply(chatID int64, userMsg string, forceKeep bool) (AIReply, b
 		chat.restart()
 	}
 
+	return ai.addReply(ctx, key, chat, userMsg)
+}
+
+func (ai *AI) EditReply(ctx context.Context, key ChatKey, index int, userMsg string) (AIReply, bool) {
+	chat, ok := ai.chats.Get(key)
+	if !ok {
+		return AIReply{}, false
+	}
+
+	chat.hstLock.Lock()
+	defer chat.hstLock.Unlock()
+
+	if index < 1 || index != len(chat.messages)-2 || chat.messages[index].Role != llms.ChatMessageTypeHuman {
+		return AIReply{}, false
+	}
+
+	messages := append([]llms.MessageContent(nil), chat.messages[index:]...)
+	msgLens := append([]int(nil), chat.msgLens[index:]...)
+	msgTimes := append([]time.Time(nil), chat.msgTimes[index:]...)
+	chat.removeLastMessage()
+	chat.removeLastMessage()
+
+	reply, ok := ai.addReply(ctx, key, chat, userMsg)
+	if !ok {
+		for len(chat.messages) > index {
+			chat.removeLastMessage()
+		}
+		chat.messages = append(chat.messages, messages...)
+		chat.msgLens = append(chat.msgLens, msgLens...)
+		chat.msgTimes = append(chat.msgTimes, msgTimes...)
+		for _, msgLen := range msgLens {
+			chat.curCtx += msgLen
+		}
+	}
+
+	return reply, ok
+}
+
+func (ai *AI) addReply(ctx context.Context, key ChatKey, chat *aiChat, userMsg string) (AIReply, bool) {
+	beginTime := time.Now().UnixNano()
+
+	if ctx.Err() != nil {
+		zayaMetrics.generations.WithLabelValues("cancelled").Inc()
+		return AIReply{}, false
+	}
+
 	chat.addUserMessage(userMsg)
 
-	resp, ok := ai.generate(chatID, chat, 1)
+	resp, ok := ai.generate(ctx, key, chat, 1, false)
+	if ctx.Err() != nil {
+		zayaMetrics.generations.WithLabelValues("cancelled").Inc()
+		chat.removeLastMessage()
+		return AIReply{}, false
+	}
+
 	if !ok {
+		zayaMetrics.generations.WithLabelValues("error").Inc()
 		chat.removeLastMessage()
 		return AIReply{}, false
 	}
 
 	if len(resp.Choices) == 0 {
-		ai.log.Warnw("no content returned from model", "chat_id", chatID)
+		zayaMetrics.generations.WithLabelValues("empty").Inc()
+		ai.log.Warnw("no content returned from model", "chat_id", key.ChatID, "thread_id", key.ThreadID)
 		return AIReply{}, false
 	}
 
@@ -329,17 +427,22 @@ func (ai *AI) GetReply(chatID int64, userMsg string, forceKeep bool) (AIReply, b
 		CtxLen: chat.curCtx,
 	}
 	if reply.Text == "" {
-		ai.log.Warnw("model reply content is empty", "chat_id", chatID)
+		zayaMetrics.generations.WithLabelValues("empty").Inc()
+		ai.log.Warnw("model reply content is empty", "chat_id", key.ChatID, "thread_id", key.ThreadID)
 		return AIReply{}, false
 	}
 
 	chat.addBotMessage(reply.Text, ai.maxTok)
 	reply.ReplyLen = chat.msgLens[len(chat.msgLens)-1]
+	reply.Index = len(chat.messages) - 2
 
 	endTime := time.Now().UnixNano()
 	duration := float64(endTime-beginTime) / 1000000
+	zayaMetrics.generations.WithLabelValues("ok").Inc()
+	zayaMetrics.genDuration.Observe(duration / 1000)
 	ai.log.Infow("ai message",
-		"chat_id", chatID,
+		"chat_id", key.ChatID,
+		"thread_id", key.ThreadID,
 		"size", reply.ReplyLen,
 		"at_end", reply.AtEnd,
 		"dur", fmt.Sprintf("%.2f", duration))
@@ -347,15 +450,178 @@ func (ai *AI) GetReply(chatID int64, userMsg string, forceKeep bool) (AIReply, b
 	return reply, true
 }
 
+func (ai *AI) Undo(key ChatKey, n int) int {
+	chat, ok := ai.chats.Get(key)
+	if !ok {
+		return 0
+	}
+
+	chat.hstLock.Lock()
+	defer chat.hstLock.Unlock()
+
+	last := len(chat.messages) - 1
+	if last > 0 && chat.messages[last].Role == llms.ChatMessageTypeHuman {
+		chat.removeLastMessage()
+	}
+
+	removed := 0
+	for removed < n && len(chat.messages) > 1 {
+		role := chat.messages[len(chat.messages)-1].Role
+		chat.removeLastMessage()
+		if role == llms.ChatMessageTypeHuman {
+			removed++
+		}
+	}
+
+	return removed
+}
+
+func (ai *AI) History(key ChatKey) ([]string, bool) {
+	chat, ok := ai.chats.Get(key)
+	if !ok {
+		return nil, false
+	}
+
+	chat.hstLock.Lock()
+	defer chat.hstLock.Unlock()
+
+	texts := make([]string, 0, len(chat.messages))
+	for _, message := range chat.messages {
+		texts = append(texts, message.Parts[0].(llms.TextContent).Text)
+	}
+
+	return texts, true
+}
+
+type HistoryMessage struct {
+	Role llms.ChatMessageType
+	Text string
+	Time time.Time
+}
+
+func (ai *AI) Transcript(key ChatKey) ([]HistoryMessage, bool) {
+	chat, ok := ai.chats.Get(key)
+	if !ok {
+		return nil, false
+	}
+
+	chat.hstLock.Lock()
+	defer chat.hstLock.Unlock()
+
+	messages := make([]HistoryMessage, 0, len(chat.messages))
+	for i, message := range chat.messages {
+		messages = append(messages, HistoryMessage{
+			Role: message.Role,
+			Text: message.Parts[0].(llms.TextContent).Text,
+			Time: chat.msgTimes[i],
+		})
+	}
+
+	return messages, true
+}
+
+func (ai *AI) RestoreHistory(key ChatKey, texts []string, maxHistory int) {
+	if len(texts) == 0 {
+		return
+	}
+
+	chat := ai.newChat(texts[0], maxHistory)
+	for i, text := range texts[1:] {
+		if i%2 == 0 {
+			chat.addUserMessage(text)
+		} else {
+			chat.addBotMessage(text, ai.maxTok)
+		}
+	}
+
+	ai.chats.Set(key, chat, ai.chatExp)
+	ai.log.Infow("chat restored", "chat_id", key.ChatID, "thread_id", key.ThreadID, "size", len(chat.messages))
+}
+
+func (ai *AI) Complete(key ChatKey, prompt, userMsg string, useAlt bool) (AIReply, bool) {
+	chat := ai.newChat(prompt, 0)
+	chat.addUserMessage(userMsg)
+
+	return ai.completeOnce(context.Background(), key, chat, useAlt)
+}
+
+func (ai *AI) CompleteChat(ctx context.Context, key ChatKey, userMsg string) (AIReply, bool) {
+	chat, ok := ai.chats.Get(key)
+	if !ok {
+		ai.log.Warnw("chat is not started", "chat_id", key.ChatID, "thread_id", key.ThreadID)
+		return AIReply{}, false
+	}
+
+	chat.hstLock.Lock()
+	once := &aiChat{
+		messages: append([]llms.MessageContent(nil), chat.messages...),
+		msgLens:  append([]int(nil), chat.msgLens...),
+		msgTimes: append([]time.Time(nil), chat.msgTimes...),
+		curCtx:   chat.curCtx,
+		maxCtx:   chat.maxCtx,
+		maxHst:   chat.maxHst,
+		lastTime: chat.lastTime,
+		log:      chat.log,
+	}
+	chat.hstLock.Unlock()
+
+	once.addUserMessage(userMsg)
+
+	return ai.completeOnce(ctx, key, once, false)
+}
+
+func (ai *AI) AddReply(key ChatKey, text string) {
+	chat, ok := ai.chats.Get(key)
+	if !ok {
+		return
+	}
+
+	chat.hstLock.Lock()
+	defer chat.hstLock.Unlock()
+
+	if chat.isExpired(ai.maxDur) {
+		chat.restart()
+	}
+
+	chat.addBotMessage(text, ai.maxTok)
+}
+
+func (ai *AI) completeOnce(ctx context.Context, key ChatKey, chat *aiChat, useAlt bool) (AIReply, bool) {
+	resp, ok := ai.generate(ctx, key, chat, 1, useAlt)
+	if !ok {
+		zayaMetrics.generations.WithLabelValues("error").Inc()
+		return AIReply{}, false
+	}
+
+	if len(resp.Choices) == 0 || resp.Choices[0].Content == "" {
+		zayaMetrics.generations.WithLabelValues("empty").Inc()
+		return AIReply{}, false
+	}
+
+	zayaMetrics.generations.WithLabelValues("ok").Inc()
+
+	text := resp.Choices[0].Content
+	return AIReply{
+		Text:     text,
+		AtEnd:    resp.Choices[0].StopReason != "length",
+		CtxLen:   chat.curCtx,
+		ReplyLen: getMessageLen(text, ai.maxTok),
+		Index:    -1,
+	}, true
+}
+
 func (ai *AI) GetAllMessages() []DialogMessage {
 	chats := ai.chats.PeekAll()
 	messages := make([]DialogMessage, 0, len(chats)*3)
 
-	for chatID, chat := range chats {
-		for _, message := range chat.messages {
+	for key, chat := range chats {
+		for i, message := range chat.messages {
 			messages = append(messages, DialogMessage{
-				ChatID: chatID,
-				Text:   message.Parts[0].(llms.TextContent).Text,
+				Model:    gorm.Model{CreatedAt: chat.msgTimes[i]},
+				ChatID:   key.ChatID,
+				ThreadID: key.ThreadID,
+				RoleID:   key.RoleID,
+				Text:     message.Parts[0].(llms.TextContent).Text,
 			})
 		}
 	}
@@ -363,17 +629,29 @@ func (ai *AI) GetAllMessages() []DialogMessage {
 	return messages
 }
 
-func (ai *AI) AddAllMessages(messages []DialogMessage, maxHst map[int64]int) {
+func (ai *AI) AddAllMessages(messages []DialogMessage, maxHst map[ChatKey]int) {
 	var chat *aiChat
-	var chatID int64
+	var key ChatKey
 	for _, msg := range messages {
-		if chatID != msg.ChatID {
-			chatID = msg.ChatID
-			chat = ai.createChat(chatID, msg.Text, maxHst[chatID])
+		msgKey := ChatKey{ChatID: msg.ChatID, ThreadID: msg.ThreadID, RoleID: msg.RoleID}
+		if chat == nil || key != msgKey {
+			key = msgKey
+			maxHistory, ok := maxHst[key]
+			if !ok {
+				maxHistory, ok = maxHst[ChatKey{ChatID: key.ChatID, ThreadID: key.ThreadID}]
+			}
+			if !ok {
+				maxHistory = maxHst[ChatKey{ChatID: key.ChatID}]
+			}
+			chat = ai.createChat(key, msg.Text, maxHistory)
 		} else if len(chat.messages)%2 == 1 {
 			chat.addUserMessage(msg.Text)
 		} else {
 			chat.addBotMessage(msg.Text, ai.maxTok)
 		}
+
+		if !msg.CreatedAt.IsZero() {
+			chat.msgTimes[len(chat.msgTimes)-1] = msg.CreatedAt
+		}
 	}
 }
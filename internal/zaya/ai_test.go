@@ -4,6 +4,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/tmc/langchaingo/llms"
 	"go.uber.org/zap/zaptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -89,6 +90,20 @@ func TestCleanHistory(t *testing.T) {
 	testContextLimit(t, chat)
 }
 
+func TestCleanHistoryKeepsLastTurn(t *testing.T) {
+	logger := zaptest.NewLogger(t).Sugar()
+	chat := newAiChat(strings.Repeat("p", 200), 100, 0, logger)
+
+	chat.addUserMessage("hi")
+	require.Equal(t, 2, chat.getMessageCount())
+	chat.addBotMessage("hello", 50)
+	require.Equal(t, 3, chat.getMessageCount())
+
+	chat.addUserMessage("again")
+	require.Equal(t, 2, chat.getMessageCount())
+	require.Equal(t, "again", chat.getMessageText(1))
+}
+
 func TestIsExpired(t *testing.T) {
 	chat := setupAiChat(t)
 	time.Sleep(5 * time.Millisecond)
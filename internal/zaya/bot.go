@@ -1,47 +1,50 @@
 package zaya
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"go.uber.org/zap"
 	tele "gopkg.in/telebot.v3"
 	"gopkg.in/telebot.v3/middleware"
-	"math"
-	"math/rand"
+	"io"
 	"strconv"
 	"strings"
 	"sync/atomic"
 	"time"
+	"unicode"
 )
 
 type Bot struct {
-	bot *tele.Bot
-	ai  *AI
-	db  *DB
-	wlc string
-	adm int64
-	log *zap.SugaredLogger
+	bot  *tele.Bot
+	core *Core
+	ai   *AI
+	db   *DB
+	adm  int64
+	log  *zap.SugaredLogger
 
 	continueMenu *tele.ReplyMarkup
 
-	startedAt   time.Time
-	aiMSgCount  atomic.Int64
-	aiMsgLength atomic.Int64
-	aiHstLength atomic.Int64
+	isRunning atomic.Bool
 }
 
-func NewBot(cfg Config, ai *AI, db *DB) (*Bot, bool) {
+func NewBot(cfg Config, core *Core) (*Bot, bool) {
 	bot := &Bot{
-		ai:        ai,
-		db:        db,
-		wlc:       cfg.Welcome,
-		adm:       cfg.AdminID,
-		log:       zap.L().Named("bot").Sugar(),
-		startedAt: time.Now(),
+		core: core,
+		ai:   core.ai,
+		db:   core.db,
+		adm:  cfg.AdminID,
+		log:  zap.L().Named("bot").Sugar(),
+	}
+
+	if cfg.TgToken == "" {
+		bot.log.Error("telegram token is required")
+		return nil, false
 	}
 
 	pref := tele.Settings{
 		Token:   cfg.TgToken,
-		Poller:  &tele.LongPoller{Timeout: 30 * time.Second},
+		Poller:  newPoller(cfg.Webhook),
 		OnError: bot.logError,
 	}
 
@@ -52,6 +55,21 @@ func NewBot(cfg Config, ai *AI, db *DB) (*Bot, bool) {
 	}
 	bot.bot = b
 
+	if cfg.Webhook.IsEnabled() {
+		bot.log.Infow("using webhook",
+			"listen", cfg.Webhook.Listen,
+			"public_url", cfg.Webhook.PublicUrl,
+			"tls", cfg.Webhook.CertPath != "",
+			"secret_token_set", cfg.Webhook.SecretToken != "")
+	} else {
+		bot.log.Info("using long polling")
+		err = bot.bot.RemoveWebhook()
+		if err != nil {
+			bot.log.Error(err)
+			return nil, false
+		}
+	}
+
 	{
 		menu := &tele.ReplyMarkup{}
 		btn := menu.Data("⇒", "continue")
@@ -63,33 +81,53 @@ func NewBot(cfg Config, ai *AI, db *DB) (*Bot, bool) {
 	bot.bot.Use(middleware.Recover())
 	bot.bot.Use(bot.logCmd)
 
-	bot.bot.Handle("/restart_chat", bot.restartChat)
-	bot.bot.Handle("/get_frequency", bot.getFrequency)
-	bot.bot.Handle("/set_frequency", bot.setFrequency)
-	bot.bot.Handle("/get_prompt", bot.getSystemPrompt)
-	bot.bot.Handle("/set_prompt", bot.setSystemPrompt)
-	bot.bot.Handle("/get_nickname", bot.getNickname)
-	bot.bot.Handle("/set_nickname", bot.setNickname)
-	bot.bot.Handle("/get_max_history", bot.getMaxHistory)
-	bot.bot.Handle("/set_max_history", bot.setMaxHistory)
-	bot.bot.Handle("/select_role", bot.selectRole)
-	bot.bot.Handle("/remove_role", bot.selectRemoveRole)
-	bot.bot.Handle("/save_role", bot.saveRole)
-	bot.bot.Handle("/help", bot.sendHelp)
-	bot.bot.Handle("/start", bot.welcome)
-	bot.bot.Handle("/get_model", bot.getCurrentModel)
-	bot.bot.Handle("/stat", bot.getBotStat)
-	bot.bot.Handle("/notify", bot.notifyUsers)
+	bot.registerCommands()
+	bot.bot.Handle(&tele.Btn{Unique: "settings"}, bot.settingsButton)
 	bot.bot.Handle(tele.OnAddedToGroup, bot.welcome)
 	bot.bot.Handle(tele.OnText, bot.readMessage)
+	bot.bot.Handle(tele.OnEdited, bot.editMessage)
+	bot.bot.Handle(tele.OnDocument, bot.documentCommand)
+	bot.bot.Handle(tele.OnMedia, bot.readMessage)
+	bot.bot.Handle(tele.OnQuery, bot.inlineQuery)
+
+	bot.core.AddFrontend(bot)
 
 	return bot, true
 }
 
+func newPoller(cfg WebhookConfig) tele.Poller {
+	if !cfg.IsEnabled() {
+		return &tele.LongPoller{Timeout: 30 * time.Second}
+	}
+
+	webhook := &tele.Webhook{
+		Listen:      cfg.Listen,
+		SecretToken: cfg.SecretToken,
+		Endpoint: &tele.WebhookEndpoint{
+			PublicURL: cfg.PublicUrl,
+		},
+	}
+
+	if cfg.CertPath != "" {
+		webhook.TLS = &tele.WebhookTLS{
+			Key:  cfg.KeyPath,
+			Cert: cfg.CertPath,
+		}
+	}
+
+	if cfg.UploadCert {
+		webhook.Endpoint.Cert = cfg.CertPath
+	}
+
+	return webhook
+}
+
 func (bot *Bot) Start() {
 	go func() {
 		bot.log.Info("starting bot")
+		bot.isRunning.Store(true)
 		bot.bot.Start()
+		bot.isRunning.Store(false)
 		bot.log.Info("bot stopped")
 	}()
 }
@@ -98,6 +136,241 @@ func (bot *Bot) Stop() {
 	bot.bot.Stop()
 }
 
+func (bot *Bot) IsRunning() bool {
+	return bot.isRunning.Load()
+}
+
+func (bot *Bot) Name() string {
+	return "telegram"
+}
+
+func (bot *Bot) BotID() int64 {
+	return bot.bot.Me.ID
+}
+
+func (bot *Bot) BotMention() string {
+	return "@" + bot.bot.Me.Username
+}
+
+func (bot *Bot) newChatRef(msg *tele.Message) ChatRef {
+	chat := ChatRef{
+		ID:      msg.Chat.ID,
+		Private: msg.Chat.Type == tele.ChatPrivate,
+	}
+
+	if msg.TopicMessage {
+		chat.ThreadID = msg.ThreadID
+	}
+
+	return chat
+}
+
+func (bot *Bot) chatRef(c tele.Context) ChatRef {
+	if c.Message() != nil {
+		return bot.newChatRef(c.Message())
+	}
+
+	return ChatRef{
+		ID:      c.Chat().ID,
+		Private: c.Chat().Type == tele.ChatPrivate,
+	}
+}
+
+func (bot *Bot) newMessage(msg *tele.Message) *Message {
+	if msg == nil {
+		return nil
+	}
+
+	m := &Message{
+		ID:          strconv.Itoa(msg.ID),
+		Chat:        bot.newChatRef(msg),
+		Text:        msg.Text,
+		Media:       mediaKind(msg),
+		ForwardFrom: forwardSource(msg),
+		ReplyTo:     bot.newMessage(msg.ReplyTo),
+	}
+
+	if m.Text == "" {
+		m.Text = msg.Caption
+	}
+
+	if msg.Sender != nil {
+		m.SenderID = msg.Sender.ID
+		m.SenderName = userName(msg.Sender)
+	}
+
+	return m
+}
+
+func userName(user *tele.User) string {
+	if user.Username != "" {
+		return user.Username
+	}
+
+	return strings.TrimSpace(user.FirstName + " " + user.LastName)
+}
+
+func mediaKind(msg *tele.Message) string {
+	switch {
+	case msg.Photo != nil:
+		return "photo"
+	case msg.Video != nil:
+		return "video"
+	case msg.Animation != nil:
+		return "animation"
+	case msg.Audio != nil:
+		return "audio"
+	case msg.Voice != nil:
+		return "voice message"
+	case msg.Document != nil:
+		return "document"
+	}
+
+	return ""
+}
+
+func forwardSource(msg *tele.Message) string {
+	switch {
+	case msg.OriginalSender != nil && msg.OriginalSender.Username != "":
+		return "@" + msg.OriginalSender.Username
+	case msg.OriginalSender != nil:
+		return strings.TrimSpace(msg.OriginalSender.FirstName + " " + msg.OriginalSender.LastName)
+	case msg.OriginalChat != nil:
+		return msg.OriginalChat.Title
+	default:
+		return msg.OriginalSenderName
+	}
+}
+
+func (bot *Bot) sendOptions(chat ChatRef, replyTo *Message, opts SendOptions) *tele.SendOptions {
+	sendOpts := &tele.SendOptions{
+		ThreadID: chat.ThreadID,
+	}
+
+	if replyTo != nil {
+		id, err := strconv.Atoi(replyTo.ID)
+		if err == nil {
+			sendOpts.ReplyTo = &tele.Message{ID: id}
+		}
+	}
+
+	if opts.Continue {
+		sendOpts.ReplyMarkup = bot.continueMenu
+	}
+
+	return sendOpts
+}
+
+func (bot *Bot) Send(chat ChatRef, replyTo *Message, text string, opts SendOptions) (*Message, error) {
+	sendOpts := bot.sendOptions(chat, replyTo, opts)
+
+	if opts.Markdown {
+		sendOpts.ParseMode = tele.ModeMarkdownV2
+		sent, err := bot.bot.Send(tele.ChatID(chat.ID), escapeSpecialChars(labelText(text, opts)), sendOpts)
+		if err == nil {
+			return bot.newMessage(sent), nil
+		}
+
+		zayaMetrics.sendErrors.WithLabelValues("reply_markdown").Inc()
+		bot.log.Warnw("error", "err", err, "text", text)
+	}
+
+	opts.Markdown = false
+	sendOpts.ParseMode = tele.ModeDefault
+	sent, err := bot.bot.Send(tele.ChatID(chat.ID), labelText(text, opts), sendOpts)
+	if err != nil {
+		zayaMetrics.sendErrors.WithLabelValues("reply").Inc()
+		return nil, telegramSendError(err)
+	}
+
+	return bot.newMessage(sent), nil
+}
+
+func telegramSendError(err error) error {
+	permanent := []error{
+		tele.ErrBlockedByUser,
+		tele.ErrKickedFromGroup,
+		tele.ErrKickedFromSuperGroup,
+		tele.ErrKickedFromChannel,
+		tele.ErrNotStartedByUser,
+		tele.ErrUserIsDeactivated,
+		tele.ErrChatNotFound,
+		tele.ErrGroupMigrated,
+	}
+
+	for _, perm := range permanent {
+		if errors.Is(err, perm) {
+			return fmt.Errorf("%w: %w", ErrChatUnavailable, err)
+		}
+	}
+
+	return err
+}
+
+func (bot *Bot) Edit(msg *Message, text string, opts SendOptions) error {
+	stored := tele.StoredMessage{MessageID: msg.ID, ChatID: msg.Chat.ID}
+	sendOpts := bot.sendOptions(msg.Chat, nil, opts)
+
+	if opts.Markdown {
+		sendOpts.ParseMode = tele.ModeMarkdownV2
+		_, err := bot.bot.Edit(stored, escapeSpecialChars(labelText(text, opts)), sendOpts)
+		if err == nil {
+			return nil
+		}
+
+		zayaMetrics.sendErrors.WithLabelValues("edit_markdown").Inc()
+		bot.log.Warnw("error", "err", err, "text", text)
+	}
+
+	opts.Markdown = false
+	sendOpts.ParseMode = tele.ModeDefault
+	_, err := bot.bot.Edit(stored, labelText(text, opts), sendOpts)
+	if err != nil {
+		zayaMetrics.sendErrors.WithLabelValues("edit").Inc()
+	}
+
+	return err
+}
+
+func (bot *Bot) SendFile(chat ChatRef, replyTo *Message, name string, data []byte) error {
+	doc := &tele.Document{
+		File:     tele.FromReader(bytes.NewReader(data)),
+		FileName: name,
+	}
+
+	_, err := bot.bot.Send(tele.ChatID(chat.ID), doc, bot.sendOptions(chat, replyTo, SendOptions{}))
+	if err != nil {
+		zayaMetrics.sendErrors.WithLabelValues("file").Inc()
+	}
+
+	return err
+}
+
+func (bot *Bot) IsChatAdmin(chat ChatRef, userID int64) bool {
+	member, err := bot.bot.ChatMemberOf(&tele.Chat{ID: chat.ID}, &tele.User{ID: userID})
+	if err != nil {
+		zayaMetrics.sendErrors.WithLabelValues("chat_member").Inc()
+		bot.log.Warnw(err.Error(), "chat_id", chat.ID, "user_id", userID)
+		return false
+	}
+
+	return member.Role == tele.Administrator || member.Role == tele.Creator
+}
+
+func (bot *Bot) Typing(chat ChatRef) error {
+	var err error
+	if chat.ThreadID != 0 {
+		err = bot.bot.Notify(tele.ChatID(chat.ID), tele.Typing, chat.ThreadID)
+	} else {
+		err = bot.bot.Notify(tele.ChatID(chat.ID), tele.Typing)
+	}
+	if err != nil {
+		zayaMetrics.sendErrors.WithLabelValues("typing").Inc()
+	}
+
+	return err
+}
+
 func (bot *Bot) logMessage(c tele.Context, beginTime int64, err error) {
 	endTime := time.Now().UnixNano()
 	duration := float64(endTime-beginTime) / 1000000
@@ -109,6 +382,7 @@ func (bot *Bot) logMessage(c tele.Context, beginTime int64, err error) {
 	}
 	bot.log.Infow("user message",
 		"chat_id", c.Chat().ID,
+		"thread_id", bot.chatRef(c).ThreadID,
 		"chat_type", c.Chat().Type,
 		"user_id", c.Sender().ID,
 		"user_name", c.Sender().Username,
@@ -164,8 +438,8 @@ func (bot *Bot) logError(err error, c tele.Context) {
 	}
 }
 
-func (bot *Bot) sendHelp(c tele.Context) error {
-	const text = "" +
+var helpIntro = map[string]string{
+	"en": "" +
 		"Greetings! I'm a sophisticated AI-powered bot, capable of assisting you with " +
 		"a multitude of tasks or engaging in captivating conversations. Feel free " +
 		"to converse with me, pose questions, and I'll respond with insightful answers.\n\n" +
@@ -177,29 +451,225 @@ func (bot *Bot) sendHelp(c tele.Context) error {
 		"of random messages to maintain a lively conversation (configurable via /set_frequency).\n\n" +
 		"The most interesting command at your disposal is /select_role. " +
 		"I encourage you to explore its possibilities.\n\n" +
-		"To customize your experience, utilize the following commands:\n\n" +
-		"To use a predefined or created persona, send /select_role, which comprises " +
-		"a system prompt, a nickname, and a history limit.\n" +
-		"To preserve the current persona for future use, send /save_role.\n" +
-		"To discard an unused persona, send /remove_role.\n" +
-		"To reboot our conversation, send /restart_chat, and I'll forget our previous messages.\n" +
-		"To access my current system instructions, send /get_prompt.\n" +
-		"To update these instructions, send /set_prompt.\n" +
-		"To view the number of messages I'll attempt to keep in my mind, send /get_max_history.\n" +
-		"To modify this number, send /set_max_history.\n" +
-		"To discover how to address me in group chats, send /get_nickname.\n" +
-		"To alter my nickname, send /set_nickname.\n" +
-		"To see how frequently I'll respond to random messages in group chats, send /get_frequency.\n" +
-		"To adjust this setting, send /set_frequency.\n" +
-		"To check which model I'm currently using, send /get_model.\n" +
-		"To revisit this guidance, send /help."
+		"To ask me something from any chat, type my username and your question there, " +
+		"start the question with #role to ask a specific role. " +
+		"Send a settings command like /set_prompt or /save_role without arguments, " +
+		"and I'll ask for each value in turn. To import a conversation or set a long prompt, " +
+		"send a document with the caption /import or /set_prompt.\n\n" +
+		"Commands:\n",
+	"ru": "" +
+		"Привет! Я бот на основе ИИ: помогу с самыми разными задачами или просто поболтаю. " +
+		"Пишите мне, задавайте вопросы, и я постараюсь ответить.\n\n" +
+		"В личном чате я отвечаю на все сообщения.\n\n" +
+		"В группах я отвечаю, когда ко мне обращаются через @, по имени (см. /get_nickname) " +
+		"или отвечают на мои сообщения. Если упомянуть меня в ответе на чужое сообщение, " +
+		"я отвечу на него. Ещё я иногда отвечаю на случайные сообщения (см. /set_frequency).\n\n" +
+		"Самая интересная команда — /select_role, попробуйте её.\n\n" +
+		"Чтобы спросить меня из любого чата, наберите там моё имя пользователя и вопрос, " +
+		"начните вопрос с #роль, чтобы спросить конкретную роль. " +
+		"Если отправить команду настройки вроде /set_prompt или /save_role без аргументов, " +
+		"я спрошу значения по очереди. Чтобы импортировать разговор или задать длинный промпт, " +
+		"отправьте документ с подписью /import или /set_prompt.\n\n" +
+		"Команды:\n",
+}
+
+func helpLang(c tele.Context) string {
+	if c.Sender() != nil {
+		_, ok := helpIntro[c.Sender().LanguageCode]
+		if ok {
+			return c.Sender().LanguageCode
+		}
+	}
 
+	return commandLangs[0]
+}
+
+func (bot *Bot) sendHelp(c tele.Context) error {
+	lang := helpLang(c)
+	private := c.Chat().Type == tele.ChatPrivate
+	botAdmin := bot.adm != 0 && c.Sender() != nil && c.Sender().ID == bot.adm
+
+	text := helpIntro[lang] + commandList(lang, "/", private, botAdmin)
 	return c.Reply(text)
 }
 
+func (bot *Bot) commandHandlers() map[string]tele.HandlerFunc {
+	return map[string]tele.HandlerFunc{
+		"start":           bot.welcome,
+		"help":            bot.sendHelp,
+		"settings":        bot.settings,
+		"restart_chat":    bot.restartChat,
+		"import":          bot.importCommand,
+		"select_role":     bot.selectRole,
+		"save_role":       bot.saveRole,
+		"remove_role":     bot.selectRemoveRole,
+		"personas":        bot.listPersonas,
+		"add_persona":     bot.selectAddPersona,
+		"remove_persona":  bot.selectRemovePersona,
+		"persona_weight":  bot.setPersonaWeight,
+		"debate":          bot.debate,
+		"stop_debate":     bot.stopDebate,
+		"get_prompt":      bot.getSystemPrompt,
+		"set_prompt":      bot.setSystemPrompt,
+		"set_max_history": bot.setMaxHistory,
+		"set_frequency":   bot.setFrequency,
+		"get_initiative":  bot.getInitiative,
+		"set_initiative":  bot.setInitiative,
+		"topic_settings":  bot.topicSettings,
+		"stat":            bot.getBotStat,
+		"notify":          bot.notifyUsers,
+	}
+}
+
+func (bot *Bot) requirePermission(cmd commandSpec, handler tele.HandlerFunc) tele.HandlerFunc {
+	switch cmd.permission {
+	case permBotAdmin:
+		return func(c tele.Context) error {
+			if bot.adm == 0 || c.Sender() == nil || c.Sender().ID != bot.adm {
+				return nil
+			}
+
+			return handler(c)
+		}
+	case permChatAdmin:
+		return func(c tele.Context) error {
+			if !isChatAdmin(bot, bot.newMessage(c.Message())) {
+				return c.Reply("Only chat admins can use this command.")
+			}
+
+			return handler(c)
+		}
+	}
+
+	return handler
+}
+
+func menuCommands(lang string, private, chatAdmin, botAdmin bool) []tele.Command {
+	menu := make([]tele.Command, 0, len(commandSpecs))
+	for _, cmd := range commandSpecs {
+		if cmd.visible(private, chatAdmin, botAdmin) {
+			menu = append(menu, tele.Command{Text: cmd.name, Description: cmd.describe(lang)})
+		}
+	}
+
+	return menu
+}
+
+func (bot *Bot) registerCommands() {
+	handlers := bot.commandHandlers()
+	for _, cmd := range commandSpecs {
+		handler, ok := handlers[cmd.name]
+		if !ok {
+			handler = bot.runCommand(cmd.name)
+		}
+
+		bot.bot.Handle("/"+cmd.name, bot.requirePermission(cmd, handler))
+	}
+
+	type menuScope struct {
+		scope     tele.CommandScope
+		private   bool
+		chatAdmin bool
+		botAdmin  bool
+	}
+
+	scopes := []menuScope{
+		{scope: tele.CommandScope{Type: tele.CommandScopeAllPrivateChats}, private: true},
+		{scope: tele.CommandScope{Type: tele.CommandScopeAllGroupChats}},
+		{scope: tele.CommandScope{Type: tele.CommandScopeAllChatAdmin}, chatAdmin: true},
+	}
+	if bot.adm != 0 {
+		scopes = append(scopes, menuScope{
+			scope:    tele.CommandScope{Type: tele.CommandScopeChat, ChatID: bot.adm},
+			private:  true,
+			botAdmin: true,
+		})
+	}
+
+	for _, s := range scopes {
+		for _, lang := range commandLangs {
+			code := lang
+			if lang == commandLangs[0] {
+				code = ""
+			}
+
+			menu := menuCommands(lang, s.private, s.chatAdmin, s.botAdmin)
+			err := bot.bot.SetCommands(menu, s.scope, code)
+			if err != nil {
+				bot.log.Warnw(err.Error(), "scope", s.scope.Type, "lang", lang)
+			}
+		}
+	}
+}
+
+func (bot *Bot) runCommand(cmd string) tele.HandlerFunc {
+	return func(c tele.Context) error {
+		msg := bot.newMessage(c.Message())
+		text, _ := bot.core.RunCommand(bot, msg, cmd, c.Message().Payload)
+		if text == "" {
+			return nil
+		}
+
+		return c.Reply(text)
+	}
+}
+
+func (bot *Bot) download(c tele.Context, doc *tele.Document, limit int64) ([]byte, bool) {
+	file, err := bot.bot.File(&doc.File)
+	if err != nil {
+		bot.log.Warnw(err.Error(), "chat_id", c.Chat().ID)
+		return nil, false
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, limit+1))
+	if err != nil {
+		bot.log.Warnw(err.Error(), "chat_id", c.Chat().ID)
+		return nil, false
+	}
+
+	return data, true
+}
+
+func (bot *Bot) importFile(c tele.Context, doc *tele.Document, arg string) error {
+	if doc.FileSize > maxImportSize {
+		return c.Reply("Can't import the file: the file is too large.")
+	}
+
+	data, ok := bot.download(c, doc, maxImportSize)
+	if !ok {
+		return c.Reply("Can't download the file, try again later.")
+	}
+
+	msg := bot.newMessage(c.Message())
+	return c.Reply(bot.core.ImportFile(bot, msg, doc.FileName, data, arg))
+}
+
+func (bot *Bot) documentCommand(c tele.Context) error {
+	cmd, arg, _ := strings.Cut(c.Message().Caption, " ")
+	cmd = strings.TrimSuffix(cmd, "@"+bot.bot.Me.Username)
+
+	switch cmd {
+	case "/import":
+		return bot.importFile(c, c.Message().Document, arg)
+	case "/set_prompt":
+		return bot.promptDocument(c, c.Message().Document)
+	}
+
+	return bot.readMessage(c)
+}
+
+func (bot *Bot) importCommand(c tele.Context) error {
+	replyTo := c.Message().ReplyTo
+	if replyTo != nil && replyTo.Document != nil {
+		return bot.importFile(c, replyTo.Document, c.Message().Payload)
+	}
+
+	return bot.runCommand("import")(c)
+}
+
 func (bot *Bot) startChat(c tele.Context) {
-	cfg := bot.db.LoadChatConfig(c.Chat().ID)
-	bot.ai.StartChat(c.Chat().ID, cfg.Prompt, cfg.MaxHistory)
+	bot.core.StartChat(bot.chatRef(c))
 }
 
 func (bot *Bot) restartChat(c tele.Context) error {
@@ -207,12 +677,6 @@ func (bot *Bot) restartChat(c tele.Context) error {
 	return c.Reply("Chat history cleared.")
 }
 
-func (bot *Bot) getFrequency(c tele.Context) error {
-	freq := bot.db.LoadChatConfig(c.Chat().ID).Freq
-	text := fmt.Sprintf("I will respond to a percentage of %d%% random messages in the chat.", freq)
-	return c.Reply(text)
-}
-
 func (bot *Bot) setFrequency(c tele.Context) error {
 	const errStr = "" +
 		"Example usage: `/set_frequency 10`.\n" +
@@ -224,6 +688,10 @@ func (bot *Bot) setFrequency(c tele.Context) error {
 		"with every message in the group chat."
 
 	args := c.Args()
+	if len(args) == 0 {
+		return bot.runCommand("set_frequency")(c)
+	}
+
 	if len(args) != 1 {
 		return c.Reply(errStr, tele.ModeMarkdown)
 	}
@@ -233,74 +701,84 @@ func (bot *Bot) setFrequency(c tele.Context) error {
 		return c.Reply(errStr, tele.ModeMarkdown)
 	}
 
-	bot.db.SetFreq(c.Chat().ID, freq)
+	bot.core.SetFreq(bot.chatRef(c), freq)
 	return c.Reply("Frequency changed.")
 }
 
 func (bot *Bot) getSystemPrompt(c tele.Context) error {
-	prompt := bot.db.LoadChatConfig(c.Chat().ID).Prompt
+	prompt := bot.core.LoadConfig(bot.chatRef(c)).Prompt
 	text := "Current system prompt:\n```\n" + prompt + "\n```"
 	return c.Reply(text, tele.ModeMarkdown)
 }
 
 func (bot *Bot) setSystemPrompt(c tele.Context) error {
-	const errStr = "Example usage: `/set_prompt You are a helpful assistant`."
-
+	prompt := ""
 	text := c.Text()
-	idx := len("/set_prompt ")
-	if len(text) <= idx {
-		return c.Reply(errStr, tele.ModeMarkdown)
+	idx := strings.IndexFunc(text, unicode.IsSpace)
+	if idx >= 0 {
+		prompt = strings.TrimSpace(text[idx:])
 	}
 
-	text = text[idx:]
-	text = strings.TrimSpace(text)
-	if len(text) == 0 {
-		return c.Reply(errStr, tele.ModeMarkdown)
+	replyTo := c.Message().ReplyTo
+	if prompt == "" && replyTo != nil {
+		if replyTo.Document != nil {
+			return bot.promptDocument(c, replyTo.Document)
+		}
+
+		prompt = replyTo.Text
+		if prompt == "" {
+			prompt = replyTo.Caption
+		}
 	}
 
-	bot.db.SetPrompt(c.Chat().ID, text)
-	bot.startChat(c)
+	if prompt == "" {
+		return bot.runCommand("set_prompt")(c)
+	}
 
-	return c.Reply("System prompt changed.")
+	return c.Reply(bot.core.ChangePrompt(bot.chatRef(c), prompt))
 }
 
-func (bot *Bot) getNickname(c tele.Context) error {
-	nickname := bot.db.LoadChatConfig(c.Chat().ID).Nickname
-	text := fmt.Sprintf("You can call me %s.", nickname)
-	return c.Reply(text)
+func (bot *Bot) promptDocument(c tele.Context, doc *tele.Document) error {
+	if doc.FileSize > maxPromptSize {
+		return c.Reply("Can't use the document as a prompt: the document is too large.")
+	}
+
+	data, ok := bot.download(c, doc, maxPromptSize)
+	if !ok {
+		return c.Reply("Can't download the file, try again later.")
+	}
+
+	return c.Reply(bot.core.ChangePromptFile(bot.chatRef(c), doc.FileName, data))
 }
 
-func (bot *Bot) setNickname(c tele.Context) error {
+func (bot *Bot) getInitiative(c tele.Context) error {
+	return c.Reply(bot.core.InitiativeInfo(bot.chatRef(c)))
+}
+
+func (bot *Bot) setInitiative(c tele.Context) error {
 	const errStr = "" +
-		"Example usage: `/set_nickname llama`.\n" +
-		"You can call me by this name."
+		"Example usage: `/set_initiative 6`.\n" +
+		"After this many hours of silence in the group chat, I will try " +
+		"to start a new conversation myself. I won't do it at night, " +
+		"and I will stop if nobody answers me a couple of times.\n" +
+		"Set it to 0, and I will never speak first."
 
 	args := c.Args()
+	if len(args) == 0 {
+		return bot.runCommand("set_initiative")(c)
+	}
+
 	if len(args) != 1 {
 		return c.Reply(errStr, tele.ModeMarkdown)
 	}
 
-	nickname := strings.ToLower(args[0])
-	bot.db.SetNickname(c.Chat().ID, nickname)
-	return c.Reply("Nickname changed.")
-}
-
-func (bot *Bot) getMaxHistory(c tele.Context) error {
-	maxHistory := bot.db.LoadChatConfig(c.Chat().ID).MaxHistory
-	if maxHistory > 0 {
-		const maxText = "" +
-			"My conversational memory will be " +
-			"capped at max %d preceding messages."
-
-		text := fmt.Sprintf(maxText, maxHistory)
-		return c.Reply(text)
+	hours, err := strconv.Atoi(args[0])
+	if err != nil || hours < 0 || hours > 24*7 {
+		return c.Reply(errStr, tele.ModeMarkdown)
 	}
 
-	const zeroText = "" +
-		"My conversational memory will be unlimited, " +
-		"retaining all preceding messages within my capabilities."
-
-	return c.Reply(zeroText)
+	bot.core.SetInitiative(bot.chatRef(c), hours)
+	return c.Reply("Initiative changed.")
 }
 
 func (bot *Bot) setMaxHistory(c tele.Context) error {
@@ -313,6 +791,10 @@ func (bot *Bot) setMaxHistory(c tele.Context) error {
 		"the context window capacity is exceeded, whichever occurs first."
 
 	args := c.Args()
+	if len(args) == 0 {
+		return bot.runCommand("set_max_history")(c)
+	}
+
 	if len(args) != 1 {
 		return c.Reply(errStr, tele.ModeMarkdown)
 	}
@@ -322,79 +804,10 @@ func (bot *Bot) setMaxHistory(c tele.Context) error {
 		return c.Reply(errStr, tele.ModeMarkdown)
 	}
 
-	bot.db.SetMaxHistory(c.Chat().ID, limit)
+	bot.core.SetMaxHistory(bot.chatRef(c), limit)
 	return c.Reply("History limit changed.")
 }
 
-func (bot *Bot) shouldReplyTo(c tele.Context) (bool, bool) {
-	if len(c.Text()) == 0 || c.Text()[0] == '/' {
-		return false, false
-	}
-
-	if c.Chat().Type == tele.ChatPrivate {
-		return true, true
-	}
-
-	if len(c.Text()) > 1000 {
-		return false, false
-	}
-
-	if c.Message().ReplyTo != nil &&
-		c.Message().ReplyTo.Sender.ID == bot.bot.Me.ID {
-		return true, true
-	}
-
-	mention := "@" + bot.bot.Me.Username
-	if strings.Contains(c.Text(), mention) {
-		return true, false
-	}
-
-	cfg := bot.db.LoadChatConfig(c.Chat().ID)
-	if strings.Contains(strings.ToLower(c.Text()), cfg.Nickname) {
-		return true, false
-	}
-
-	if rand.Intn(100) < cfg.Freq {
-		return true, cfg.Freq == 100
-	}
-
-	return false, false
-}
-
-func (bot *Bot) sendAiReply(msg *tele.Message, userMsg string, isReply bool) error {
-	err := bot.bot.Notify(msg.Chat, tele.Typing)
-	if err != nil {
-		bot.log.Warnw(err.Error(), "chat_id", msg.Chat.ID)
-	}
-
-	ch := make(chan AIReply)
-	defer close(ch)
-
-	ticker := time.NewTicker(time.Second * 3)
-	defer ticker.Stop()
-
-	go func() {
-		reply, ok := bot.ai.GetReply(msg.Chat.ID, userMsg, isReply)
-		if ok {
-			ch <- reply
-		} else {
-			ch <- AIReply{}
-		}
-	}()
-
-	for {
-		select {
-		case <-ticker.C:
-			err = bot.bot.Notify(msg.Chat, tele.Typing)
-			if err != nil {
-				bot.log.Warnw(err.Error(), "chat_id", msg.Chat.ID)
-			}
-		case reply := <-ch:
-			return bot.sendReply(msg, reply)
-		}
-	}
-}
-
 func escapeSpecialChars(s string) string {
 	var result strings.Builder
 	result.Grow(len(s))
@@ -448,76 +861,77 @@ func escapeSpecialChars(s string) string {
 	return result.String()
 }
 
-func (bot *Bot) sendReply(msg *tele.Message, reply AIReply) error {
-	if reply.Text == "" {
-		return nil
+func (bot *Bot) welcome(c tele.Context) error {
+	err := bot.sendHelp(c)
+	if err != nil {
+		return err
 	}
 
-	bot.aiMSgCount.Add(1)
-	bot.aiMsgLength.Add(int64(reply.ReplyLen))
-	bot.aiHstLength.Add(int64(reply.CtxLen))
-
-	escapedText := escapeSpecialChars(reply.Text)
-
-	var err error
-	if reply.AtEnd {
-		_, err = bot.bot.Reply(msg, escapedText, tele.ModeMarkdownV2)
-	} else {
-		_, err = bot.bot.Reply(msg, escapedText, bot.continueMenu, tele.ModeMarkdownV2)
-	}
+	return bot.core.Welcome(bot, bot.newMessage(c.Message()))
+}
 
-	if err != nil {
-		bot.log.Warnw("error", "err", err, "text", reply.Text)
+func (bot *Bot) readMessage(c tele.Context) error {
+	beginTime := time.Now().UnixNano()
 
-		if reply.AtEnd {
-			_, err = bot.bot.Reply(msg, reply.Text, tele.ModeDefault)
-		} else {
-			_, err = bot.bot.Reply(msg, reply.Text, bot.continueMenu, tele.ModeDefault)
-		}
+	replied, err := bot.core.ReadMessage(bot, bot.newMessage(c.Message()))
+	if replied {
+		bot.logMessage(c, beginTime, err)
 	}
 
 	return err
 }
 
-func (bot *Bot) welcome(c tele.Context) error {
-	err := bot.sendHelp(c)
-	if err != nil {
-		return err
+func (bot *Bot) editMessage(c tele.Context) error {
+	beginTime := time.Now().UnixNano()
+
+	edited, err := bot.core.EditMessage(bot, bot.newMessage(c.Message()))
+	if edited {
+		bot.logMessage(c, beginTime, err)
 	}
 
-	bot.startChat(c)
-	return bot.sendAiReply(c.Message(), bot.wlc, true)
+	return err
 }
 
-func (bot *Bot) readMessage(c tele.Context) error {
-	beginTime := time.Now().UnixNano()
-
-	shouldReply, forceKeepHistory := bot.shouldReplyTo(c)
-	if !shouldReply {
-		return nil
+func (bot *Bot) inlineQuery(c tele.Context) error {
+	query := c.Query()
+	resp := &tele.QueryResponse{
+		Results:    tele.Results{},
+		CacheTime:  1,
+		IsPersonal: true,
 	}
 
-	mention := "@" + bot.bot.Me.Username
-	msg := c.Message()
-	text := c.Text()
-	if msg.ReplyTo != nil && msg.ReplyTo.Text != "" &&
-		msg.Sender.ID != bot.bot.Me.ID &&
-		strings.Contains(text, mention) {
-		msg = msg.ReplyTo
-		text = msg.Text
+	answer, ok := bot.core.AnswerInline(query.Sender.ID, query.Text)
+	if !ok {
+		return c.Answer(resp)
 	}
-	text = strings.ReplaceAll(text, mention, "")
-	text = strings.TrimSpace(text)
 
-	if !bot.ai.IsChatStarted(c.Chat().ID) {
-		bot.startChat(c)
+	description := []rune(answer)
+	if len(description) > 100 {
+		description = append(description[:99], '…')
 	}
 
-	err := bot.sendAiReply(msg, text, forceKeepHistory)
+	result := &tele.ArticleResult{
+		Title:       query.Text,
+		Description: string(description),
+	}
+	resp.Results = append(resp.Results, result)
+	resp.CacheTime = 60
+
+	opts := SendOptions{Markdown: true, Label: query.Text}
+	result.Text = escapeSpecialChars(labelText(answer, opts))
+	result.ParseMode = tele.ModeMarkdownV2
+	err := c.Answer(resp)
+	if err == nil {
+		return nil
+	}
 
-	bot.logMessage(c, beginTime, err)
+	zayaMetrics.sendErrors.WithLabelValues("inline_markdown").Inc()
+	bot.log.Warnw("error", "err", err, "text", answer)
 
-	return err
+	opts.Markdown = false
+	result.Text = labelText(answer, opts)
+	result.ParseMode = tele.ModeDefault
+	return c.Answer(resp)
 }
 
 func (bot *Bot) continueAiReply(c tele.Context) error {
@@ -528,15 +942,97 @@ func (bot *Bot) continueAiReply(c tele.Context) error {
 		return err
 	}
 
-	if bot.ai.IsChatStarted(c.Chat().ID) {
-		err = bot.sendAiReply(c.Message(), "continue", true)
-	}
+	err = bot.core.ContinueReply(bot, bot.newMessage(c.Message()))
 
 	bot.logMessage(c, beginTime, err)
 
 	return err
 }
 
+func (bot *Bot) topicSettings(c tele.Context) error {
+	const errStr = "" +
+		"Example usage: `/topic_settings on`.\n" +
+		"Send `on` inside a forum topic, and I will copy the chat settings " +
+		"to this topic, so the prompt, nickname, history limit and frequency " +
+		"may be changed for this topic only.\n" +
+		"Send `off`, and the topic will follow the chat settings again."
+
+	chat := bot.chatRef(c)
+	if chat.ThreadID == 0 {
+		return c.Reply("This command works only inside forum topics.")
+	}
+
+	args := c.Args()
+	if len(args) != 1 {
+		_, isTopic := bot.db.LoadTopicConfig(chat.ID, chat.ThreadID)
+		state := "This topic follows the chat settings.\n\n"
+		if isTopic {
+			state = "This topic has its own settings.\n\n"
+		}
+
+		return c.Reply(state+errStr, tele.ModeMarkdown)
+	}
+
+	switch args[0] {
+	case "on":
+		bot.db.CreateTopicConfig(chat.ID, chat.ThreadID)
+		bot.core.StartChat(chat)
+		return c.Reply("This topic has its own settings now.")
+	case "off":
+		bot.db.RemoveTopicConfig(chat.ID, chat.ThreadID)
+		bot.core.StartChat(chat)
+		return c.Reply("This topic follows the chat settings now.")
+	}
+
+	return c.Reply(errStr, tele.ModeMarkdown)
+}
+
+func (bot *Bot) settingsMenu(panel SettingsPanel) *tele.ReplyMarkup {
+	menu := &tele.ReplyMarkup{}
+	rows := make([]tele.Row, 0, len(panel.Rows))
+	for _, buttons := range panel.Rows {
+		row := make(tele.Row, 0, len(buttons))
+		for _, button := range buttons {
+			row = append(row, menu.Data(button.Text, "settings", button.Data))
+		}
+		rows = append(rows, row)
+	}
+	menu.Inline(rows...)
+
+	return menu
+}
+
+func (bot *Bot) settings(c tele.Context) error {
+	panel, ok := bot.core.Settings(bot, bot.newMessage(c.Message()))
+	if !ok {
+		return c.Reply(panel.Text)
+	}
+
+	return c.Reply(panel.Text, bot.settingsMenu(panel))
+}
+
+func (bot *Bot) settingsButton(c tele.Context) error {
+	msg := bot.newMessage(c.Message())
+	msg.SenderID = c.Sender().ID
+	msg.SenderName = userName(c.Sender())
+
+	update := bot.core.ApplySetting(bot, msg, c.Data())
+
+	err := c.Edit(update.Panel.Text, bot.settingsMenu(update.Panel))
+	if err != nil && !errors.Is(err, tele.ErrSameMessageContent) {
+		bot.log.Warnw(err.Error(), "chat_id", msg.Chat.ID)
+	}
+
+	if update.Message != "" {
+		_, err = bot.Send(msg.Chat, nil, update.Message, SendOptions{})
+		if err != nil {
+			bot.log.Warnw(err.Error(), "chat_id", msg.Chat.ID)
+		}
+	}
+
+	return c.Respond(&tele.CallbackResponse{Text: update.Notice})
+}
+
 func (bot *Bot) createRoleMenu(roles []BotRole, unique string, handler tele.HandlerFunc) *tele.ReplyMarkup {
 	roleMenu := &tele.ReplyMarkup{}
 	roleBtns := make([]tele.Btn, 0)
@@ -570,8 +1066,7 @@ func (bot *Bot) setRole(c tele.Context) error {
 		return err
 	}
 
-	role, ok := bot.db.SetRole(c.Chat().ID, uint(id))
-	bot.startChat(c)
+	role, ok := bot.core.SetRole(bot.chatRef(c), uint(id))
 	if ok {
 		text := fmt.Sprintf("Now I'm acting as *%s*.", role.Name)
 		if role.Example != "" {
@@ -623,6 +1118,118 @@ func (bot *Bot) removeRole(c tele.Context) error {
 	return c.Respond()
 }
 
+func (bot *Bot) listPersonas(c tele.Context) error {
+	return c.Reply(bot.core.PersonaList(bot.chatRef(c)))
+}
+
+func (bot *Bot) selectAddPersona(c tele.Context) error {
+	roles := bot.db.LoadAllRoleNames(c.Chat().ID)
+	roleMenu := bot.createRoleMenu(roles, "add_persona", bot.addPersona)
+	const text = "" +
+		"Select a role to join this chat as another persona. " +
+		"It will answer to its own nickname, and its replies will be labeled with its name."
+
+	return c.Reply(text, roleMenu)
+}
+
+func (bot *Bot) addPersona(c tele.Context) error {
+	id, err := strconv.Atoi(c.Args()[0])
+	if err != nil {
+		return err
+	}
+
+	role, ok := bot.core.AddPersona(bot.chatRef(c), uint(id), 1)
+	if ok {
+		text := fmt.Sprintf("*%s* joined the chat. Call it _%s_.", role.Name, role.Nickname)
+		err = c.Edit(text, tele.ModeMarkdown)
+	} else {
+		err = c.Edit("Can't add this persona.")
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.Respond()
+}
+
+func (bot *Bot) selectRemovePersona(c tele.Context) error {
+	text := "Select a persona to dismiss."
+
+	personas := bot.db.LoadPersonas(c.Chat().ID, bot.chatRef(c).ThreadID)
+	if len(personas) > 0 {
+		roles := make([]BotRole, 0, len(personas))
+		for _, persona := range personas {
+			role := BotRole{Name: persona.Name}
+			role.ID = persona.RoleID
+			roles = append(roles, role)
+		}
+
+		roleMenu := bot.createRoleMenu(roles, "remove_persona", bot.removePersona)
+		return c.Reply(text, roleMenu)
+	}
+
+	text += "\n(no personas)"
+	return c.Reply(text)
+}
+
+func (bot *Bot) removePersona(c tele.Context) error {
+	id, err := strconv.Atoi(c.Args()[0])
+	if err != nil {
+		return err
+	}
+
+	ok := bot.core.RemovePersona(bot.chatRef(c), uint(id))
+	if ok {
+		err = c.Edit("Persona dismissed.")
+	} else {
+		err = c.Edit("Can't dismiss this persona.")
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.Respond()
+}
+
+func (bot *Bot) setPersonaWeight(c tele.Context) error {
+	const errStr = "" +
+		"Example usage: `/persona_weight 3 Assistant`.\n" +
+		"The first argument is a weight, the second one is the persona name. " +
+		"A persona with weight 3 will chime in to random messages three times " +
+		"as often as a persona with weight 1. Weight 0 means the persona " +
+		"will respond only when called by its nickname."
+
+	args := c.Args()
+	if len(args) < 2 {
+		return c.Reply(errStr, tele.ModeMarkdown)
+	}
+
+	weight, err := strconv.Atoi(args[0])
+	if err != nil || weight < 0 {
+		return c.Reply(errStr, tele.ModeMarkdown)
+	}
+
+	name := strings.Join(args[1:], " ")
+	if !bot.core.SetPersonaWeight(bot.chatRef(c), name, weight) {
+		return c.Reply("There is no such persona in this chat.")
+	}
+
+	return c.Reply("Persona weight changed.")
+}
+
+func (bot *Bot) debate(c tele.Context) error {
+	text := bot.core.Debate(bot, bot.newMessage(c.Message()), c.Message().Payload)
+	return c.Reply(text)
+}
+
+func (bot *Bot) stopDebate(c tele.Context) error {
+	if !bot.core.StopDebate(bot.chatRef(c)) {
+		return c.Reply("There is no debate going on.")
+	}
+
+	return c.Reply("Stopping the debate.")
+}
+
 func (bot *Bot) saveRole(c tele.Context) error {
 	const errStr = "" +
 		"Example usage: `/save_role en Assistant`.\n" +
@@ -633,6 +1240,10 @@ func (bot *Bot) saveRole(c tele.Context) error {
 		"limited to a maximum of 20 characters."
 
 	args := c.Args()
+	if len(args) == 0 {
+		return bot.runCommand("save_role")(c)
+	}
+
 	if len(args) < 2 {
 		return c.Reply(errStr, tele.ModeMarkdown)
 	}
@@ -652,26 +1263,6 @@ func (bot *Bot) saveRole(c tele.Context) error {
 	return c.Reply("Role saved.")
 }
 
-func (bot *Bot) getCurrentModel(c tele.Context) error {
-	const msgTxt = "" +
-		"I am currently utilizing the <b>%s</b> model.\n" +
-		"The primary model is a more recent and sophisticated iteration of the LLM, " +
-		"albeit with certain usage limits. Occasionally, in the event of exceeding " +
-		"the allocated quota, I can seamlessly transition to the auxiliary model. " +
-		"It is essential to note that these limitations are universally applied, " +
-		"affecting all users collectively rather than individually. " +
-		"While the auxiliary model may not possess the same level of capabilities " +
-		"as its primary counterpart, it is more than adequate for the vast majority of tasks."
-
-	model := "primary"
-	if bot.ai.IsAltModel() {
-		model = "auxiliary"
-	}
-
-	msg := fmt.Sprintf(msgTxt, model)
-	return c.Reply(msg, tele.ModeHTML)
-}
-
 func (bot *Bot) notifyUsers(c tele.Context) error {
 	if c.Sender().ID != bot.adm {
 		return nil
@@ -689,10 +1280,11 @@ func (bot *Bot) notifyUsers(c tele.Context) error {
 	}
 
 	sentCnt := 0
-	chatIDs := bot.db.LoadAllChatIDs()
+	chatIDs := bot.db.LoadTelegramChatIDs()
 	for _, chatID := range chatIDs {
 		_, err := bot.bot.Send(tele.ChatID(chatID), text, tele.ModeMarkdown)
 		if err != nil {
+			zayaMetrics.sendErrors.WithLabelValues("notify").Inc()
 			bot.log.Warnw("error", "chat", chatID, "err", err)
 		} else {
 			sentCnt++
@@ -705,45 +1297,5 @@ func (bot *Bot) notifyUsers(c tele.Context) error {
 }
 
 func (bot *Bot) getBotStat(c tele.Context) error {
-	var msg strings.Builder
-
-	addF64 := func(title string, value float64) {
-		if math.IsInf(value, 0) || math.IsNaN(value) {
-			value = 0
-		}
-
-		msg.WriteString(fmt.Sprintf("%s: %.2f\n", title, value))
-	}
-
-	addI64 := func(title string, value int64) {
-		msg.WriteString(fmt.Sprintf("%s: %d\n", title, value))
-	}
-
-	uptimeDays := time.Now().Sub(bot.startedAt).Hours() / 24
-	addF64("Uptime (days)", uptimeDays)
-
-	totalMsgCnt := bot.aiMSgCount.Load()
-	addI64("Total count of output messages", totalMsgCnt)
-
-	totalMsgLen := bot.aiMsgLength.Load()
-	addI64("Total length of output messages (KiB)", totalMsgLen/1024)
-
-	if uptimeDays > 0.1 {
-		avgMsgCnt := float64(totalMsgCnt) / uptimeDays
-		addF64("Count of output messages per day", avgMsgCnt)
-
-		avgMsgLen := float64(totalMsgLen) / 1024 / uptimeDays
-		addF64("Length of output messages per day (KiB)", avgMsgLen)
-
-		avgHstLen := float64(bot.aiHstLength.Load()) / 1024 / uptimeDays
-		addF64("Length of input context per day (KiB)", avgHstLen)
-	}
-
-	groupChatCnt := bot.db.GetChatCount()
-	addI64("Total count of chats", groupChatCnt)
-
-	customRoleCnt := bot.db.GetCustomRoleCount()
-	addI64("Count of custom roles", customRoleCnt)
-
-	return c.Reply(msg.String(), tele.ModeHTML)
+	return c.Reply(bot.core.GetStat(), tele.ModeHTML)
 }
@@ -2,6 +2,8 @@ package zaya
 
 import (
 	"github.com/stretchr/testify/require"
+	tele "gopkg.in/telebot.v3"
+	"regexp"
 	"testing"
 )
 
@@ -85,3 +87,130 @@ func TestEscapeSpecialChars(t *testing.T) {
 		})
 	}
 }
+
+func TestNewPoller(t *testing.T) {
+	poller := newPoller(WebhookConfig{})
+	_, ok := poller.(*tele.LongPoller)
+	require.True(t, ok)
+
+	poller = newPoller(WebhookConfig{
+		Listen:      ":8443",
+		PublicUrl:   "https://example.com/zaya",
+		CertPath:    "cert.pem",
+		KeyPath:     "key.pem",
+		SecretToken: "secret",
+	})
+	webhook, ok := poller.(*tele.Webhook)
+	require.True(t, ok)
+	require.Equal(t, ":8443", webhook.Listen)
+	require.Equal(t, "secret", webhook.SecretToken)
+	require.Equal(t, "https://example.com/zaya", webhook.Endpoint.PublicURL)
+	require.Equal(t, "", webhook.Endpoint.Cert)
+	require.Equal(t, "cert.pem", webhook.TLS.Cert)
+	require.Equal(t, "key.pem", webhook.TLS.Key)
+}
+
+func TestCommandRegistry(t *testing.T) {
+	handlers := (&Bot{}).commandHandlers()
+	nameRe := regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+	names := make(map[string]bool)
+	for _, cmd := range commandSpecs {
+		require.Regexp(t, nameRe, cmd.name)
+		require.False(t, names[cmd.name], cmd.name)
+		names[cmd.name] = true
+
+		if cmd.telegramOnly {
+			require.NotNil(t, handlers[cmd.name], cmd.name)
+		}
+		for _, lang := range commandLangs {
+			text, ok := cmd.description[lang]
+			require.True(t, ok, cmd.name+" "+lang)
+			require.True(t, len([]rune(text)) >= 3 && len([]rune(text)) <= 256, cmd.name)
+		}
+	}
+
+	for name := range handlers {
+		require.True(t, names[name], name)
+	}
+
+	menuNames := func(menu []tele.Command) []string {
+		names := make([]string, 0, len(menu))
+		for _, cmd := range menu {
+			names = append(names, cmd.Text)
+		}
+		return names
+	}
+
+	private := menuNames(menuCommands("en", true, false, false))
+	require.Contains(t, private, "start")
+	require.Contains(t, private, "export")
+	require.NotContains(t, private, "summarize")
+	require.NotContains(t, private, "notify")
+
+	group := menuNames(menuCommands("en", false, false, false))
+	require.Contains(t, group, "summarize")
+	require.NotContains(t, group, "start")
+	require.NotContains(t, group, "export")
+	require.NotContains(t, group, "notify")
+
+	admins := menuNames(menuCommands("ru", false, true, false))
+	require.Contains(t, admins, "summarize")
+	require.Contains(t, admins, "export")
+	require.NotContains(t, admins, "set_limit")
+
+	owner := menuNames(menuCommands("en", true, false, true))
+	require.Contains(t, owner, "notify")
+	require.Contains(t, owner, "set_limit")
+	require.Less(t, len(owner), 100)
+
+	menu := menuCommands("ru", true, false, false)
+	require.Equal(t, "Начать разговор", menu[0].Description)
+}
+
+func TestCommandList(t *testing.T) {
+	text := commandList("en", "/", false, false)
+	require.Contains(t, text, "/summarize [N|1h|today] - Recap the recent group discussion")
+	require.Contains(t, text, "/export [md|txt|json] [prompt] - Send the conversation as a md, txt or json file (admins only)")
+	require.NotContains(t, text, "/start ")
+	require.NotContains(t, text, "/notify")
+	require.NotContains(t, text, "/set_limit")
+
+	text = commandList("ru", "/", true, true)
+	require.Contains(t, text, "/start - Начать разговор")
+	require.Contains(t, text, "/notify <text> - ")
+	require.NotContains(t, text, "(только админы)")
+
+	text = commandList("en", "/", false, true)
+	require.Contains(t, text, "/set_limit <limit> <value> - Change the quota limits of this chat")
+	require.Contains(t, text, "/summarize ")
+	require.NotContains(t, text, "/start ")
+
+	for _, cmd := range commandSpecs {
+		if cmd.scope == scopeAll && cmd.permission == permAnyone {
+			require.Contains(t, helpIntro["en"]+commandList("en", "/", true, false), "/"+cmd.name+" ")
+		}
+	}
+}
+
+func TestNewMessage(t *testing.T) {
+	bot := &Bot{}
+	msg := bot.newMessage(&tele.Message{
+		ID:      7,
+		Chat:    &tele.Chat{ID: -1, Type: tele.ChatGroup},
+		Sender:  &tele.User{ID: 5, FirstName: "Ann", LastName: "Lee"},
+		Photo:   &tele.Photo{},
+		Caption: "what breed is it?",
+		ReplyTo: &tele.Message{
+			Chat:   &tele.Chat{ID: -1, Type: tele.ChatGroup},
+			Sender: &tele.User{ID: 6, Username: "bob"},
+			Text:   "my dog",
+		},
+	})
+
+	require.Equal(t, "what breed is it?", msg.Text)
+	require.Equal(t, "photo", msg.Media)
+	require.Equal(t, "Ann Lee", msg.SenderName)
+	require.Equal(t, "bob", msg.ReplyTo.SenderName)
+	require.Empty(t, msg.ReplyTo.Media)
+}
@@ -0,0 +1,118 @@
+package zaya
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+type senderKey struct {
+	chat   ChatKey
+	sender int64
+}
+
+type burst struct {
+	key        senderKey
+	msg        *Message
+	texts      []string
+	lastAt     time.Time
+	cancel     context.CancelFunc
+	registered bool
+}
+
+func (core *Core) startBurst(fe Frontend, msg *Message, text string) *burst {
+	b := &burst{
+		key:    senderKey{chat: msg.Chat.Key(), sender: msg.SenderID},
+		msg:    msg,
+		texts:  []string{text},
+		lastAt: time.Now(),
+	}
+
+	if core.debounce <= 0 || msg.SenderID == fe.BotID() {
+		return b
+	}
+
+	core.burstLock.Lock()
+	defer core.burstLock.Unlock()
+
+	_, exists := core.bursts[b.key]
+	if !exists {
+		core.bursts[b.key] = b
+		b.registered = true
+	}
+
+	return b
+}
+
+func (core *Core) mergeBurst(fe Frontend, msg *Message) bool {
+	if core.debounce <= 0 || msg.SenderID == fe.BotID() || strings.HasPrefix(msg.Text, "/") {
+		return false
+	}
+
+	text := core.userTurn(fe, msg)
+	if text == "" {
+		return false
+	}
+
+	core.burstLock.Lock()
+	defer core.burstLock.Unlock()
+
+	b, ok := core.bursts[senderKey{chat: msg.Chat.Key(), sender: msg.SenderID}]
+	if !ok {
+		return false
+	}
+
+	b.msg = msg
+	b.texts = append(b.texts, text)
+	b.lastAt = time.Now()
+	if b.cancel != nil {
+		b.cancel()
+	}
+
+	core.log.Infow("message merged", "chat_id", msg.Chat.ID, "thread_id", msg.Chat.ThreadID, "count", len(b.texts))
+	zayaMetrics.mergedMessages.Inc()
+
+	return true
+}
+
+func (core *Core) burstTurn(b *burst) (context.Context, *Message, string, int) {
+	for {
+		core.burstLock.Lock()
+		wait := time.Until(b.lastAt.Add(core.debounce))
+		if wait <= 0 {
+			ctx, cancel := context.WithCancel(context.Background())
+			b.cancel = cancel
+			msg, text, n := b.msg, strings.Join(b.texts, "\n"), len(b.texts)
+			core.burstLock.Unlock()
+
+			return ctx, msg, text, n
+		}
+		core.burstLock.Unlock()
+
+		time.Sleep(wait)
+	}
+}
+
+func (core *Core) finishTurn(b *burst, n int, replied bool) bool {
+	core.burstLock.Lock()
+	defer core.burstLock.Unlock()
+
+	b.cancel()
+	b.cancel = nil
+
+	if replied {
+		b.texts = b.texts[n:]
+	} else if len(b.texts) == n {
+		b.texts = nil
+	}
+
+	if len(b.texts) > 0 {
+		return true
+	}
+
+	if b.registered {
+		delete(core.bursts, b.key)
+	}
+
+	return false
+}
@@ -0,0 +1,80 @@
+package zaya
+
+import (
+	"github.com/stretchr/testify/require"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMergeBurst(t *testing.T) {
+	core, llm := setupTestCore(t)
+	core.debounce = 50 * time.Millisecond
+	fe := &testFrontend{}
+	chat := ChatRef{ID: 1, Private: true}
+
+	done := make(chan bool)
+	go func() {
+		replied, _ := core.ReadMessage(fe, &Message{Chat: chat, SenderID: 1, Text: "one"})
+		done <- replied
+	}()
+
+	require.Eventually(t, func() bool {
+		core.burstLock.Lock()
+		defer core.burstLock.Unlock()
+		return len(core.bursts) == 1
+	}, time.Second, time.Millisecond)
+
+	replied, err := core.ReadMessage(fe, &Message{Chat: chat, SenderID: 1, Text: "two"})
+	require.NoError(t, err)
+	require.True(t, replied)
+	require.True(t, <-done)
+
+	require.Equal(t, []string{"re: one\ntwo"}, fe.sentTexts())
+	require.Len(t, llm.lastMessages, 2)
+	require.Empty(t, core.bursts)
+
+	replied, err = core.ReadMessage(fe, &Message{Chat: chat, SenderID: 1, Text: "three"})
+	require.NoError(t, err)
+	require.True(t, replied)
+	require.Equal(t, "re: three", fe.sentTexts()[1])
+}
+
+func TestCancelBurst(t *testing.T) {
+	core, llm := setupTestCore(t)
+	core.debounce = 10 * time.Millisecond
+	fe := &testFrontend{}
+	chat := ChatRef{ID: 1, Private: true}
+
+	var calls atomic.Int64
+	started := make(chan struct{})
+	release := make(chan struct{})
+	llm.respond = func(_, text string) string {
+		if calls.Add(1) == 1 {
+			close(started)
+			<-release
+		}
+		return "re: " + text
+	}
+
+	done := make(chan bool)
+	go func() {
+		replied, _ := core.ReadMessage(fe, &Message{Chat: chat, SenderID: 1, Text: "one"})
+		done <- replied
+	}()
+
+	<-started
+	replied, err := core.ReadMessage(fe, &Message{Chat: chat, SenderID: 1, Text: "two"})
+	require.NoError(t, err)
+	require.True(t, replied)
+	close(release)
+	require.True(t, <-done)
+
+	require.Equal(t, int64(2), calls.Load())
+	require.Equal(t, []string{"re: one\ntwo"}, fe.sentTexts())
+
+	chats := core.ai.GetAllMessages()
+	require.Len(t, chats, 3)
+	require.Equal(t, "one\ntwo", chats[1].Text)
+	require.Equal(t, "re: one\ntwo", chats[2].Text)
+}
@@ -0,0 +1,170 @@
+package zaya
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxUndo        = 20
+	maxCheckpoints = 20
+)
+
+var checkpointNameRe = regexp.MustCompile(`^[\p{L}\d_-]{1,32}$`)
+
+type checkpointChat struct {
+	RoleID   uint     `json:"role_id"`
+	Messages []string `json:"messages"`
+}
+
+func (core *Core) targetPersona(msg *Message) Persona {
+	personas := core.loadPersonas(msg.Chat, core.LoadConfig(msg.Chat))
+
+	persona, ok := core.repliedPersona(msg.ReplyTo, personas)
+	if ok {
+		return persona
+	}
+
+	turn, ok := core.turns.Get(msg.Chat.Key())
+	if ok {
+		return turn.persona
+	}
+
+	return personas[0]
+}
+
+func (core *Core) Undo(msg *Message, arg string) string {
+	n := 1
+	if arg != "" {
+		var err error
+		n, err = strconv.Atoi(arg)
+		if err != nil || n < 1 || n > maxUndo {
+			return fmt.Sprintf("Example usage: undo 2\nI can undo from 1 to %d exchanges at once.", maxUndo)
+		}
+	}
+
+	removed := core.ai.Undo(core.targetPersona(msg).chatKey(msg.Chat), n)
+	if removed == 0 {
+		return "There is nothing to undo."
+	}
+
+	core.turns.Remove(msg.Chat.Key())
+
+	if removed == 1 {
+		return "The last exchange is forgotten."
+	}
+
+	return fmt.Sprintf("The last %d exchanges are forgotten.", removed)
+}
+
+func (core *Core) chatKeys(chat ChatRef) []ChatKey {
+	keys := []ChatKey{chat.Key()}
+	for _, persona := range core.db.LoadPersonas(chat.ID, chat.ThreadID) {
+		keys = append(keys, persona.chatKey(chat))
+	}
+
+	return keys
+}
+
+func (core *Core) checkpointList(chat ChatRef) string {
+	checkpoints := core.db.LoadCheckpoints(chat.ID, chat.ThreadID)
+	if len(checkpoints) == 0 {
+		return "There are no checkpoints."
+	}
+
+	loc := core.Location(chat)
+	names := make([]string, 0, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		names = append(names, fmt.Sprintf("%s - %s",
+			checkpoint.Name, checkpoint.UpdatedAt.In(loc).Format(reminderTimeLayout)))
+	}
+
+	return "Checkpoints:\n" + strings.Join(names, "\n")
+}
+
+func (core *Core) Checkpoint(chat ChatRef, name string) string {
+	name = strings.ToLower(name)
+	if name == "" {
+		return core.checkpointList(chat)
+	}
+
+	if !checkpointNameRe.MatchString(name) {
+		return "Example usage: checkpoint dungeon_entrance\nThe name may contain letters, digits, _ and -."
+	}
+
+	_, exists := core.db.LoadCheckpoint(chat.ID, chat.ThreadID, name)
+	if !exists && len(core.db.LoadCheckpoints(chat.ID, chat.ThreadID)) >= maxCheckpoints {
+		return "Can't save the checkpoint, there are too many of them in this chat."
+	}
+
+	chats := make([]checkpointChat, 0)
+	for _, key := range core.chatKeys(chat) {
+		messages, ok := core.ai.History(key)
+		if ok && len(messages) > 1 {
+			chats = append(chats, checkpointChat{RoleID: key.RoleID, Messages: messages})
+		}
+	}
+
+	if len(chats) == 0 {
+		return "There is nothing to save yet."
+	}
+
+	history, err := json.Marshal(chats)
+	if err != nil {
+		core.log.Warnw(err.Error(), "chat_id", chat.ID)
+		return "Can't save the checkpoint."
+	}
+
+	checkpoint := &Checkpoint{
+		ChatID:   chat.ID,
+		ThreadID: chat.ThreadID,
+		Name:     name,
+		History:  string(history),
+	}
+	if !core.db.SaveCheckpoint(checkpoint) {
+		return "Can't save the checkpoint."
+	}
+
+	return fmt.Sprintf("Checkpoint %s saved.", name)
+}
+
+func (core *Core) Restore(chat ChatRef, name string) string {
+	name = strings.ToLower(name)
+	if name == "" {
+		return core.checkpointList(chat)
+	}
+
+	checkpoint, ok := core.db.LoadCheckpoint(chat.ID, chat.ThreadID, name)
+	if !ok {
+		return "There is no such checkpoint."
+	}
+
+	var chats []checkpointChat
+	err := json.Unmarshal([]byte(checkpoint.History), &chats)
+	if err != nil {
+		core.log.Warnw(err.Error(), "chat_id", chat.ID)
+		return "Can't restore the checkpoint."
+	}
+
+	cfg := core.LoadConfig(chat)
+	personas := core.loadPersonas(chat, cfg)
+	for _, saved := range chats {
+		maxHistory := cfg.MaxHistory
+		for _, persona := range personas {
+			if persona.RoleID == saved.RoleID {
+				maxHistory = persona.MaxHistory
+			}
+		}
+
+		key := chat.Key()
+		key.RoleID = saved.RoleID
+		core.ai.RestoreHistory(key, saved.Messages, maxHistory)
+	}
+
+	core.turns.Remove(chat.Key())
+
+	return fmt.Sprintf("Checkpoint %s restored.", name)
+}
@@ -0,0 +1,72 @@
+package zaya
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestUndo(t *testing.T) {
+	core, _ := setupTestCore(t)
+	fe := &testFrontend{}
+	chat := ChatRef{ID: 1, Private: true}
+	msg := &Message{Chat: chat}
+
+	for _, text := range []string{"one", "two", "three"} {
+		_, err := core.ReadMessage(fe, &Message{Chat: chat, Text: text})
+		require.NoError(t, err)
+	}
+
+	text, _ := core.RunCommand(fe, msg, "undo", "0")
+	require.Contains(t, text, "Example usage")
+
+	text, _ = core.RunCommand(fe, msg, "undo", "2")
+	require.Equal(t, "The last 2 exchanges are forgotten.", text)
+
+	history, ok := core.ai.History(chat.Key())
+	require.True(t, ok)
+	require.Equal(t, []string{defaultCfg.Prompt, "one", "re: one"}, history)
+
+	text, _ = core.RunCommand(fe, msg, "undo", "5")
+	require.Equal(t, "The last exchange is forgotten.", text)
+
+	text, _ = core.RunCommand(fe, msg, "undo", "")
+	require.Equal(t, "There is nothing to undo.", text)
+}
+
+func TestCheckpoints(t *testing.T) {
+	core, _ := setupTestCore(t)
+	fe := &testFrontend{}
+	chat := ChatRef{ID: 1, Private: true}
+	msg := &Message{Chat: chat}
+
+	text, _ := core.RunCommand(fe, msg, "checkpoint", "start")
+	require.Equal(t, "There is nothing to save yet.", text)
+
+	_, err := core.ReadMessage(fe, &Message{Chat: chat, Text: "enter the cave"})
+	require.NoError(t, err)
+
+	text, _ = core.RunCommand(fe, msg, "checkpoint", "Cave")
+	require.Equal(t, "Checkpoint cave saved.", text)
+
+	text, _ = core.RunCommand(fe, msg, "checkpoint", "bad name!")
+	require.Contains(t, text, "Example usage")
+
+	_, err = core.ReadMessage(fe, &Message{Chat: chat, Text: "fight the dragon"})
+	require.NoError(t, err)
+
+	text, _ = core.RunCommand(fe, msg, "restore", "")
+	require.Contains(t, text, "Checkpoints:\ncave - ")
+
+	text, _ = core.RunCommand(fe, msg, "restore", "dragon")
+	require.Equal(t, "There is no such checkpoint.", text)
+
+	text, _ = core.RunCommand(fe, msg, "restore", "cave")
+	require.Equal(t, "Checkpoint cave restored.", text)
+
+	history, ok := core.ai.History(chat.Key())
+	require.True(t, ok)
+	require.Equal(t, []string{defaultCfg.Prompt, "enter the cave", "re: enter the cave"}, history)
+
+	_, ok = core.db.LoadCheckpoint(-5, 0, "cave")
+	require.False(t, ok)
+}
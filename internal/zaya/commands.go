@@ -0,0 +1,198 @@
+package zaya
+
+import (
+	"strings"
+)
+
+type commandScope int
+
+const (
+	scopeAll commandScope = iota
+	scopePrivate
+	scopeGroup
+	scopeAdmin
+)
+
+type commandPermission int
+
+const (
+	permAnyone commandPermission = iota
+	permChatAdmin
+	permBotAdmin
+)
+
+var commandLangs = []string{"en", "ru"}
+
+type commandSpec struct {
+	name         string
+	usage        string
+	description  map[string]string
+	scope        commandScope
+	permission   commandPermission
+	telegramOnly bool
+}
+
+func (cmd commandSpec) describe(lang string) string {
+	text, ok := cmd.description[lang]
+	if !ok {
+		text = cmd.description["en"]
+	}
+
+	return text
+}
+
+func (cmd commandSpec) line(prefix, lang string) string {
+	line := prefix + cmd.name
+	if cmd.usage != "" {
+		line += " " + cmd.usage
+	}
+
+	return line + " - " + cmd.describe(lang)
+}
+
+func (cmd commandSpec) visible(private, chatAdmin, botAdmin bool) bool {
+	switch {
+	case cmd.scope == scopePrivate && !private:
+		return false
+	case cmd.scope == scopeGroup && private:
+		return false
+	case cmd.scope == scopeAdmin || cmd.permission == permBotAdmin:
+		return botAdmin
+	case cmd.permission == permChatAdmin:
+		return chatAdmin || private
+	}
+
+	return true
+}
+
+func desc(en, ru string) map[string]string {
+	return map[string]string{"en": en, "ru": ru}
+}
+
+var commandSpecs = []commandSpec{
+	{name: "start", scope: scopePrivate, telegramOnly: true,
+		description: desc("Start a conversation", "Начать разговор")},
+	{name: "help",
+		description: desc("Show what I can do", "Показать, что я умею")},
+	{name: "settings", permission: permChatAdmin,
+		description: desc("Change the main settings with buttons", "Изменить основные настройки кнопками")},
+	{name: "restart_chat",
+		description: desc("Forget our previous messages", "Забыть предыдущие сообщения")},
+	{name: "undo", usage: "[n]",
+		description: desc("Forget the last exchanges, add a number for several", "Забыть последние реплики, можно указать число")},
+	{name: "checkpoint", usage: "[name]",
+		description: desc("Save the conversation under a name", "Сохранить разговор под именем")},
+	{name: "restore", usage: "[name]",
+		description: desc("Get back to a saved conversation", "Вернуться к сохранённому разговору")},
+	{name: "export", usage: "[md|txt|json] [prompt]", permission: permChatAdmin,
+		description: desc("Send the conversation as a md, txt or json file", "Выгрузить разговор в файл md, txt или json")},
+	{name: "import", usage: "[confirm|cancel]", permission: permChatAdmin,
+		description: desc("Continue an exported conversation from a file", "Продолжить выгруженный разговор из файла")},
+	{name: "select_role", usage: "[id]",
+		description: desc("Pick a role with its prompt, nickname and history limit", "Выбрать роль с промптом, именем и лимитом истории")},
+	{name: "save_role", usage: "<lang> <name>",
+		description: desc("Save the current persona as a role", "Сохранить текущую персону как роль")},
+	{name: "remove_role", telegramOnly: true,
+		description: desc("Remove a role created in this chat", "Удалить роль, созданную в этом чате")},
+	{name: "personas",
+		description: desc("List personas living in this chat", "Показать персон в этом чате")},
+	{name: "add_persona", usage: "<id> [weight]",
+		description: desc("Let one more persona live in this chat", "Добавить ещё одну персону в чат")},
+	{name: "remove_persona", usage: "<id>",
+		description: desc("Dismiss a persona", "Убрать персону")},
+	{name: "persona_weight", usage: "<weight> <name>",
+		description: desc("Make a persona chime in more or less often", "Изменить, как часто вступает персона")},
+	{name: "persona_aliases", usage: "<name> <aliases>",
+		description: desc("Let a persona answer to more names", "Задать персоне дополнительные имена")},
+	{name: "debate", usage: "<role> <role> <topic> [turns]",
+		description: desc("Watch two roles argue about a topic", "Устроить спор двух ролей")},
+	{name: "stop_debate",
+		description: desc("Stop the debate", "Остановить спор")},
+	{name: "get_prompt",
+		description: desc("Show the system prompt", "Показать системный промпт")},
+	{name: "set_prompt", usage: "<text>",
+		description: desc("Change the system prompt, reply to a text or a document for a long one", "Изменить системный промпт, для длинного ответьте на текст или документ")},
+	{name: "get_max_history",
+		description: desc("Show how many messages I keep in mind", "Показать, сколько сообщений я помню")},
+	{name: "set_max_history", usage: "<n>",
+		description: desc("Change how many messages I keep in mind", "Изменить, сколько сообщений я помню")},
+	{name: "get_nickname",
+		description: desc("Show how to address me in groups", "Показать, как ко мне обращаться в группах")},
+	{name: "set_nickname", usage: "<name>[, alias...]",
+		description: desc("Change my nickname or give me several aliases", "Изменить моё имя или задать несколько")},
+	{name: "test_trigger", usage: "<phrase>",
+		description: desc("Check whether a phrase would wake me up", "Проверить, отзовусь ли я на фразу")},
+	{name: "get_frequency", scope: scopeGroup,
+		description: desc("Show how often I reply to random messages", "Показать, как часто я отвечаю на случайные сообщения")},
+	{name: "set_frequency", usage: "<0..100>", scope: scopeGroup,
+		description: desc("Change how often I reply to random messages", "Изменить, как часто я отвечаю на случайные сообщения")},
+	{name: "get_relevance", scope: scopeGroup,
+		description: desc("Show the relevance threshold for chiming in", "Показать порог уместности для ответов")},
+	{name: "set_relevance", usage: "<0..100>", scope: scopeGroup,
+		description: desc("Chime in only on relevant messages, 0 for random replies", "Отвечать только на уместные сообщения, 0 для случайных")},
+	{name: "get_initiative", scope: scopeGroup,
+		description: desc("Show after how many quiet hours I speak up", "Показать, через сколько часов тишины я заговорю")},
+	{name: "set_initiative", usage: "<hours>", scope: scopeGroup,
+		description: desc("Let me speak up when the group goes quiet", "Разрешить мне заговорить, когда в группе тихо")},
+	{name: "summarize", usage: "[N|1h|today]", scope: scopeGroup,
+		description: desc("Recap the recent group discussion", "Кратко пересказать недавнее обсуждение")},
+	{name: "topic_settings", scope: scopeGroup, telegramOnly: true,
+		description: desc("Keep separate settings for a forum topic", "Отдельные настройки для темы форума")},
+	{name: "remind", usage: "<time> <text>",
+		description: desc("Remind about something, e.g. tomorrow 9:00 call mom", "Напомнить о чём-то, например tomorrow 9:00 позвонить маме")},
+	{name: "reminders",
+		description: desc("List reminders", "Показать напоминания")},
+	{name: "cancel_reminder", usage: "<id>",
+		description: desc("Cancel a reminder", "Отменить напоминание")},
+	{name: "get_timezone",
+		description: desc("Show the chat timezone", "Показать часовой пояс чата")},
+	{name: "set_timezone", usage: "<zone>",
+		description: desc("Change the chat timezone", "Изменить часовой пояс чата")},
+	{name: "cancel",
+		description: desc("Stop answering my questions for a command", "Прекратить ввод значений для команды")},
+	{name: "quota",
+		description: desc("Show the messages and tokens left", "Показать оставшиеся сообщения и токены")},
+	{name: "get_model",
+		description: desc("Show which model I'm using", "Показать, какую модель я использую")},
+	{name: "stat", telegramOnly: true,
+		description: desc("Show bot statistics", "Показать статистику бота")},
+	{name: "set_limit", usage: "<limit> <value>", scope: scopeAdmin, permission: permBotAdmin,
+		description: desc("Change the quota limits of this chat", "Изменить лимиты этого чата")},
+	{name: "notify", usage: "<text>", scope: scopeAdmin, permission: permBotAdmin, telegramOnly: true,
+		description: desc("Send a message to all users", "Отправить сообщение всем пользователям")},
+}
+
+func commandList(lang, prefix string, private, botAdmin bool) string {
+	lines := make([]string, 0, len(commandSpecs))
+	for _, cmd := range commandSpecs {
+		if !cmd.visible(private, true, botAdmin) {
+			continue
+		}
+
+		line := cmd.line(prefix, lang)
+		if cmd.permission == permChatAdmin && !private {
+			line += adminsOnly[lang]
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+var adminsOnly = map[string]string{
+	"en": " (admins only)",
+	"ru": " (только админы)",
+}
+
+func (core *Core) CommandHelp(prefix string) string {
+	lines := make([]string, 0, len(commandSpecs))
+	for _, cmd := range commandSpecs {
+		if cmd.telegramOnly || cmd.permission == permBotAdmin {
+			continue
+		}
+
+		lines = append(lines, cmd.line(prefix, commandLangs[0]))
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -2,9 +2,12 @@ package zaya
 
 import (
 	"errors"
+	"fmt"
 	"github.com/knadh/koanf/parsers/toml"
 	"github.com/knadh/koanf/providers/file"
 	"github.com/knadh/koanf/v2"
+	"regexp"
+	"strings"
 	"time"
 )
 
@@ -14,15 +17,24 @@ type Config struct {
 	RolesPath  string `koanf:"roles_path"`
 	Welcome    string
 	Release    bool
+	Debounce   time.Duration
 	AdminID    int64         `koanf:"admin_id"`
 	DefaultCfg DefaultConfig `koanf:"default_cfg"`
 	Ai         AiConfig
+	Http       HttpConfig
+	Webhook    WebhookConfig
+	Matrix     MatrixConfig
+	Initiative InitiativeConfig
+	Limits     LimitsConfig
+	Turn       TurnConfig
+	Relevance  RelevanceConfig
 }
 
 type DefaultConfig struct {
 	Freq     int
 	Nickname string
 	Prompt   string
+	Timezone string
 }
 
 type AiConfig struct {
@@ -39,6 +51,181 @@ type AiConfig struct {
 	ExpTime  time.Duration `koanf:"exp_time"`
 	ChatExp  time.Duration `koanf:"chat_exp"`
 	Stop     []string
+	Workers  int
+}
+
+func (cfg AiConfig) workers() int {
+	if cfg.Workers <= 0 {
+		return defaultWorkers
+	}
+
+	return cfg.Workers
+}
+
+type HttpConfig struct {
+	Listen string
+}
+
+type WebhookConfig struct {
+	Listen      string
+	PublicUrl   string `koanf:"public_url"`
+	CertPath    string `koanf:"cert_path"`
+	KeyPath     string `koanf:"key_path"`
+	UploadCert  bool   `koanf:"upload_cert"`
+	SecretToken string `koanf:"secret_token"`
+}
+
+type LimitsConfig struct {
+	UserRate  float64 `koanf:"user_rate"`
+	UserBurst int     `koanf:"user_burst"`
+	UserDaily int     `koanf:"user_daily"`
+	ChatRate  float64 `koanf:"chat_rate"`
+	ChatBurst int     `koanf:"chat_burst"`
+	ChatDaily int     `koanf:"chat_daily"`
+}
+
+type InitiativeConfig struct {
+	ActiveFrom    int           `koanf:"active_from"`
+	ActiveTo      int           `koanf:"active_to"`
+	DailyCap      int           `koanf:"daily_cap"`
+	MaxUnanswered int           `koanf:"max_unanswered"`
+	CheckInterval time.Duration `koanf:"check_interval"`
+	Prompt        string
+}
+
+func (cfg InitiativeConfig) withDefaults() InitiativeConfig {
+	if cfg.ActiveFrom == 0 && cfg.ActiveTo == 0 {
+		cfg.ActiveFrom = 10
+		cfg.ActiveTo = 22
+	}
+
+	if cfg.DailyCap <= 0 {
+		cfg.DailyCap = 2
+	}
+
+	if cfg.MaxUnanswered <= 0 {
+		cfg.MaxUnanswered = 2
+	}
+
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = 10 * time.Minute
+	}
+
+	if cfg.Prompt == "" {
+		cfg.Prompt = "" +
+			"Nobody has written to the chat for a while. " +
+			"Start a new conversation: share a thought, a story or ask a question " +
+			"related to your persona or to what we talked about before."
+	}
+
+	return cfg
+}
+
+func (cfg InitiativeConfig) isActiveHour(hour int) bool {
+	if cfg.ActiveFrom <= cfg.ActiveTo {
+		return hour >= cfg.ActiveFrom && hour < cfg.ActiveTo
+	}
+
+	return hour >= cfg.ActiveFrom || hour < cfg.ActiveTo
+}
+
+type RelevanceConfig struct {
+	Mode     string
+	Cooldown time.Duration
+}
+
+func (cfg RelevanceConfig) withDefaults() RelevanceConfig {
+	if cfg.Mode == "" {
+		cfg.Mode = relevanceHeuristic
+	}
+
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = 10 * time.Minute
+	}
+
+	return cfg
+}
+
+func (cfg RelevanceConfig) validate() error {
+	mode := cfg.withDefaults().Mode
+	if mode != relevanceHeuristic && mode != relevanceModel {
+		return fmt.Errorf("relevance mode must be %s or %s", relevanceHeuristic, relevanceModel)
+	}
+
+	return nil
+}
+
+type TurnConfig struct {
+	Template string
+	MaxQuote int `koanf:"max_quote"`
+}
+
+func (cfg TurnConfig) withDefaults() TurnConfig {
+	if cfg.Template == "" {
+		cfg.Template = "" +
+			"{{if .Forward}}Forwarded from {{.Forward}}:\n{{end}}" +
+			"{{if .Quote}}> {{if .QuoteAuthor}}{{.QuoteAuthor}}: {{end}}{{.Quote}}\n{{end}}" +
+			"{{if .Media}}[{{.Media}}] {{end}}{{.Text}}"
+	}
+
+	if cfg.MaxQuote <= 0 {
+		cfg.MaxQuote = 300
+	}
+
+	return cfg
+}
+
+func (cfg TurnConfig) validate() error {
+	_, err := parseTurnTemplate(cfg.withDefaults().Template)
+	if err != nil {
+		return fmt.Errorf("turn template: %w", err)
+	}
+
+	return nil
+}
+
+type MatrixConfig struct {
+	Homeserver  string
+	UserID      string `koanf:"user_id"`
+	AccessToken string `koanf:"access_token"`
+}
+
+func (cfg MatrixConfig) IsEnabled() bool {
+	return cfg.Homeserver != ""
+}
+
+var secretTokenRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+func (cfg WebhookConfig) IsEnabled() bool {
+	return cfg.Listen != ""
+}
+
+func (cfg WebhookConfig) validate() error {
+	if !cfg.IsEnabled() {
+		return nil
+	}
+
+	if cfg.PublicUrl == "" {
+		return errors.New("webhook public url is required")
+	}
+
+	if !strings.HasPrefix(cfg.PublicUrl, "https://") {
+		return errors.New("webhook public url must use https")
+	}
+
+	if (cfg.CertPath == "") != (cfg.KeyPath == "") {
+		return errors.New("webhook requires both cert and key paths or none of them")
+	}
+
+	if cfg.UploadCert && cfg.CertPath == "" {
+		return errors.New("webhook cert path is required to upload the certificate")
+	}
+
+	if cfg.SecretToken != "" && !secretTokenRe.MatchString(cfg.SecretToken) {
+		return errors.New("webhook secret token may contain only A-Z, a-z, 0-9, _ and -")
+	}
+
+	return nil
 }
 
 func LoadConfig() (Config, error) {
@@ -56,8 +243,19 @@ func LoadConfig() (Config, error) {
 		return cfg, err
 	}
 
-	if cfg.TgToken == "" {
-		return cfg, errors.New("telegram token is required")
+	err = cfg.Webhook.validate()
+	if err != nil {
+		return cfg, err
+	}
+
+	err = cfg.Turn.validate()
+	if err != nil {
+		return cfg, err
+	}
+
+	err = cfg.Relevance.validate()
+	if err != nil {
+		return cfg, err
 	}
 
 	return cfg, nil
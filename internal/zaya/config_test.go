@@ -0,0 +1,84 @@
+package zaya
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestWebhookConfigValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   WebhookConfig
+		valid bool
+	}{
+		{
+			name:  "Disabled",
+			cfg:   WebhookConfig{},
+			valid: true,
+		},
+		{
+			name:  "Without public url",
+			cfg:   WebhookConfig{Listen: ":8443"},
+			valid: false,
+		},
+		{
+			name:  "Plain http public url",
+			cfg:   WebhookConfig{Listen: ":8443", PublicUrl: "http://example.com"},
+			valid: false,
+		},
+		{
+			name:  "Behind reverse proxy",
+			cfg:   WebhookConfig{Listen: ":8443", PublicUrl: "https://example.com"},
+			valid: true,
+		},
+		{
+			name:  "Cert without key",
+			cfg:   WebhookConfig{Listen: ":8443", PublicUrl: "https://example.com", CertPath: "cert.pem"},
+			valid: false,
+		},
+		{
+			name:  "Upload without cert",
+			cfg:   WebhookConfig{Listen: ":8443", PublicUrl: "https://example.com", UploadCert: true},
+			valid: false,
+		},
+		{
+			name: "Self-signed TLS",
+			cfg: WebhookConfig{Listen: ":8443", PublicUrl: "https://example.com",
+				CertPath: "cert.pem", KeyPath: "key.pem", UploadCert: true},
+			valid: true,
+		},
+		{
+			name:  "Invalid secret token",
+			cfg:   WebhookConfig{Listen: ":8443", PublicUrl: "https://example.com", SecretToken: "a b"},
+			valid: false,
+		},
+		{
+			name:  "Valid secret token",
+			cfg:   WebhookConfig{Listen: ":8443", PublicUrl: "https://example.com", SecretToken: "a-B_9"},
+			valid: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.validate()
+			if tt.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestInitiativeActiveHours(t *testing.T) {
+	cfg := InitiativeConfig{}.withDefaults()
+	require.False(t, cfg.isActiveHour(9))
+	require.True(t, cfg.isActiveHour(10))
+	require.False(t, cfg.isActiveHour(22))
+
+	cfg = InitiativeConfig{ActiveFrom: 20, ActiveTo: 2}.withDefaults()
+	require.True(t, cfg.isActiveHour(23))
+	require.True(t, cfg.isActiveHour(1))
+	require.False(t, cfg.isActiveHour(12))
+}
@@ -0,0 +1,207 @@
+package zaya
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	consoleUserID int64 = 1
+	consoleBotID  int64 = 2
+)
+
+type Console struct {
+	core   *Core
+	in     *bufio.Scanner
+	out    io.Writer
+	chat   ChatRef
+	lastID int
+	last   *Message
+}
+
+func NewConsole(core *Core, in io.Reader, out io.Writer) (*Console, bool) {
+	chatID, ok := core.db.LoadExternalChatID("console", "local")
+	if !ok {
+		return nil, false
+	}
+
+	con := &Console{
+		core: core,
+		in:   bufio.NewScanner(in),
+		out:  out,
+		chat: ChatRef{
+			ID:      chatID,
+			Private: true,
+		},
+	}
+	core.AddFrontend(con)
+
+	return con, true
+}
+
+func (con *Console) Name() string {
+	return "console"
+}
+
+func (con *Console) BotID() int64 {
+	return consoleBotID
+}
+
+func (con *Console) BotMention() string {
+	return "@zaya"
+}
+
+func (con *Console) nextID() string {
+	con.lastID++
+	return strconv.Itoa(con.lastID)
+}
+
+func (con *Console) Send(chat ChatRef, _ *Message, text string, opts SendOptions) (*Message, error) {
+	opts.Markdown = false
+	text = labelText(text, opts)
+
+	msg := &Message{
+		ID:       con.nextID(),
+		Chat:     chat,
+		SenderID: consoleBotID,
+		Text:     text,
+	}
+
+	_, err := fmt.Fprintf(con.out, "\n%s\n\n", text)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Continue {
+		_, err = fmt.Fprintln(con.out, "(send /continue to get the rest)")
+	}
+
+	con.last = msg
+	return msg, err
+}
+
+func (con *Console) Edit(msg *Message, text string, opts SendOptions) error {
+	opts.Markdown = false
+	text = labelText(text, opts)
+
+	msg.Text = text
+	_, err := fmt.Fprintf(con.out, "\n(edited #%s)\n%s\n\n", msg.ID, text)
+	return err
+}
+
+func (con *Console) SendFile(_ ChatRef, _ *Message, name string, data []byte) error {
+	err := os.WriteFile(name, data, 0o644)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(con.out, "\n(saved %s)\n\n", name)
+	return err
+}
+
+func (con *Console) Typing(ChatRef) error {
+	return nil
+}
+
+func (con *Console) print(format string, args ...any) {
+	_, _ = fmt.Fprintf(con.out, format+"\n", args...)
+}
+
+func (con *Console) Run() {
+	con.print("Type a message to chat or /help to list commands.")
+
+	for {
+		_, _ = fmt.Fprint(con.out, "> ")
+		if !con.in.Scan() {
+			return
+		}
+
+		text := strings.TrimSpace(con.in.Text())
+		if text == "" {
+			continue
+		}
+
+		if text == "/quit" || text == "/exit" {
+			return
+		}
+
+		if text[0] == '/' {
+			con.runCommand(text)
+			continue
+		}
+
+		con.readMessage(text, nil)
+	}
+}
+
+func (con *Console) readMessage(text string, replyTo *Message) {
+	msg := &Message{
+		ID:         con.nextID(),
+		Chat:       con.chat,
+		SenderID:   consoleUserID,
+		SenderName: "console",
+		Text:       text,
+		ReplyTo:    replyTo,
+	}
+
+	replied, err := con.core.ReadMessage(con, msg)
+	if err != nil {
+		con.print("error: %s", err)
+	} else if !replied {
+		con.print("(no reply)")
+	}
+}
+
+func (con *Console) runCommand(text string) {
+	cmd, arg, _ := strings.Cut(text[1:], " ")
+	arg = strings.TrimSpace(arg)
+
+	switch cmd {
+	case "help":
+		con.print("%s\n%s", con.core.CommandHelp("/"), ""+
+			"/continue - continue the last reply\n"+
+			"/reply <text> - reply to the last bot message\n"+
+			"/private, /group - switch the chat type\n"+
+			"/quit - exit")
+	case "continue":
+		if con.last == nil {
+			con.print("Nothing to continue.")
+			return
+		}
+		err := con.core.ContinueReply(con, con.last)
+		if err != nil {
+			con.print("error: %s", err)
+		}
+	case "reply":
+		if con.last == nil || arg == "" {
+			con.print("Example usage: /reply What do you mean?")
+			return
+		}
+		con.readMessage(arg, con.last)
+	case "private":
+		con.chat.Private = true
+		con.print("Switched to a private chat.")
+	case "group":
+		con.chat.Private = false
+		con.print("Switched to a group chat.")
+	default:
+		msg := &Message{
+			Chat:       con.chat,
+			SenderID:   consoleUserID,
+			SenderName: "console",
+			Text:       text,
+		}
+
+		reply, ok := con.core.RunCommand(con, msg, cmd, arg)
+		if !ok {
+			reply = "Unknown command, send /help."
+		}
+		if reply != "" {
+			con.print("%s", reply)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package zaya
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestConsole(t *testing.T) {
+	core, _ := setupTestCore(t)
+	chatID, ok := core.db.LoadExternalChatID("console", "local")
+	require.True(t, ok)
+	core.db.SetFreq(chatID, 0)
+	core.db.SaveRole(chatID, "en", "Tester")
+	roles := core.db.LoadChatRoleNames(chatID)
+	require.Len(t, roles, 1)
+
+	in := strings.NewReader("" +
+		"hello\n" +
+		"/select_role\n" +
+		"/set_prompt be brief\n" +
+		"/group\n" +
+		"nobody is here\n" +
+		"/reply ping\n" +
+		"/quit\n" +
+		"ignored\n")
+	out := &bytes.Buffer{}
+
+	console, ok := NewConsole(core, in, out)
+	require.True(t, ok)
+	console.Run()
+
+	text := out.String()
+	require.Contains(t, text, "re: hello")
+	require.Contains(t, text, "Tester")
+	require.Contains(t, text, "System prompt changed, it takes 1% of the context.")
+	require.Contains(t, text, "(no reply)")
+	require.Contains(t, text, "re: ping")
+	require.NotContains(t, text, "ignored")
+	require.Equal(t, "be brief", core.db.LoadChatConfig(chatID).Prompt)
+}
@@ -0,0 +1,761 @@
+package zaya
+
+import (
+	"context"
+	"fmt"
+	"github.com/erni27/imcache"
+	"go.uber.org/zap"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+type Core struct {
+	ai  *AI
+	db  *DB
+	wlc string
+	log *zap.SugaredLogger
+
+	personaMsgs imcache.Cache[string, uint]
+	personaExp  imcache.Expiration
+	turns       imcache.Cache[ChatKey, answeredTurn]
+	imports     imcache.Cache[senderKey, pendingImport]
+	flows       imcache.Cache[senderKey, flow]
+
+	debates     map[ChatKey]*debate
+	debateLock  sync.Mutex
+	debateDelay time.Duration
+
+	initiative   InitiativeConfig
+	activity     map[ChatKey]*chatActivity
+	activityLock sync.Mutex
+	stop         chan struct{}
+	wg           sync.WaitGroup
+
+	recent     map[ChatKey][]bufferedMessage
+	recentLock sync.Mutex
+
+	inlineCache    imcache.Cache[string, string]
+	inlineLimits   imcache.Cache[int64, struct{}]
+	inlineInterval time.Duration
+	inlineLatest   map[int64]uint64
+	inlineSeq      uint64
+	inlineLock     sync.Mutex
+	inlineDebounce time.Duration
+
+	scheduler *scheduler
+
+	turn     TurnConfig
+	turnTmpl *template.Template
+
+	relevance RelevanceConfig
+	cooldowns imcache.Cache[ChatKey, struct{}]
+
+	bursts    map[senderKey]*burst
+	burstLock sync.Mutex
+	debounce  time.Duration
+
+	limits       LimitsConfig
+	adminID      int64
+	quotaLock    sync.Mutex
+	quotaNotices imcache.Cache[ChatKey, struct{}]
+
+	frontends        map[string]Frontend
+	frontendLock     sync.Mutex
+	reminderInterval time.Duration
+
+	startedAt   time.Time
+	aiMSgCount  atomic.Int64
+	aiMsgLength atomic.Int64
+	aiHstLength atomic.Int64
+}
+
+func NewCore(ai *AI, db *DB, cfg Config) *Core {
+	turn := cfg.Turn.withDefaults()
+
+	return &Core{
+		ai:          ai,
+		db:          db,
+		wlc:         cfg.Welcome,
+		log:         zap.L().Named("core").Sugar(),
+		personaExp:  imcache.WithSlidingExpiration(72 * time.Hour),
+		debates:     make(map[ChatKey]*debate),
+		debateDelay: 5 * time.Second,
+		initiative:  cfg.Initiative.withDefaults(),
+		activity:    make(map[ChatKey]*chatActivity),
+		stop:        make(chan struct{}),
+		recent:      make(map[ChatKey][]bufferedMessage),
+		scheduler:   newScheduler(cfg.Ai.workers()),
+		turn:        turn,
+		turnTmpl:    template.Must(parseTurnTemplate(turn.Template)),
+		relevance:   cfg.Relevance.withDefaults(),
+		bursts:      make(map[senderKey]*burst),
+		debounce:    cfg.Debounce,
+		limits:      cfg.Limits,
+		adminID:     cfg.AdminID,
+		frontends:   make(map[string]Frontend),
+		startedAt:   time.Now(),
+
+		reminderInterval: 30 * time.Second,
+		inlineInterval:   3 * time.Second,
+		inlineLatest:     make(map[int64]uint64),
+		inlineDebounce:   700 * time.Millisecond,
+	}
+}
+
+func (core *Core) Start() {
+	core.loadActivity()
+
+	core.wg.Add(2)
+	go core.runInitiative()
+	go core.runReminders()
+}
+
+func (core *Core) Stop() {
+	close(core.stop)
+	core.wg.Wait()
+	core.scheduler.stop()
+	core.saveActivity()
+}
+
+func (core *Core) StartChat(chat ChatRef) {
+	cfg := core.LoadConfig(chat)
+	core.ai.StartChat(chat.Key(), cfg.Prompt, cfg.MaxHistory)
+
+	for _, persona := range core.db.LoadPersonas(chat.ID, chat.ThreadID) {
+		core.ai.StartChat(persona.chatKey(chat), persona.Prompt, persona.MaxHistory)
+	}
+}
+
+func (core *Core) LoadConfig(chat ChatRef) *ChatConfig {
+	cfg, _ := core.db.LoadTopicConfig(chat.ID, chat.ThreadID)
+	return cfg
+}
+
+func (core *Core) SetFreq(chat ChatRef, freq int) {
+	values := map[string]any{"freq": freq}
+	if !core.db.UpdateTopicConfig(chat.ID, chat.ThreadID, values) {
+		core.db.SetFreq(chat.ID, freq)
+	}
+}
+
+func (core *Core) SetNickname(chat ChatRef, nickname string) bool {
+	aliases, ok := parseAliases(nickname)
+	if !ok {
+		return false
+	}
+
+	nickname = aliases[0]
+	extra := strings.Join(aliases[1:], ", ")
+
+	values := map[string]any{"nickname": nickname, "aliases": extra}
+	if !core.db.UpdateTopicConfig(chat.ID, chat.ThreadID, values) {
+		core.db.SetNickname(chat.ID, nickname)
+		core.db.SetAliases(chat.ID, extra)
+	}
+
+	return true
+}
+
+func (core *Core) SetPrompt(chat ChatRef, prompt string) {
+	values := map[string]any{"prompt": prompt}
+	if !core.db.UpdateTopicConfig(chat.ID, chat.ThreadID, values) {
+		core.db.SetPrompt(chat.ID, prompt)
+	}
+
+	core.StartChat(chat)
+}
+
+func (core *Core) SetMaxHistory(chat ChatRef, maxHistory int) {
+	values := map[string]any{"max_history": clampMaxHistory(maxHistory)}
+	if !core.db.UpdateTopicConfig(chat.ID, chat.ThreadID, values) {
+		core.db.SetMaxHistory(chat.ID, maxHistory)
+	}
+}
+
+func (core *Core) SetInitiative(chat ChatRef, hours int) {
+	values := map[string]any{"initiative": hours}
+	if !core.db.UpdateTopicConfig(chat.ID, chat.ThreadID, values) {
+		core.db.SetInitiative(chat.ID, hours)
+	}
+}
+
+func (core *Core) SetRelevance(chat ChatRef, threshold int) {
+	values := map[string]any{"relevance": threshold}
+	if !core.db.UpdateTopicConfig(chat.ID, chat.ThreadID, values) {
+		core.db.SetRelevance(chat.ID, threshold)
+	}
+}
+
+func (core *Core) SetRole(chat ChatRef, roleID uint) (*BotRole, bool) {
+	role, ok := core.db.LoadRole(chat.ID, roleID)
+	if !ok || !core.promptFits(role.Prompt) {
+		return nil, false
+	}
+
+	values := map[string]any{
+		"max_history": role.MaxHistory,
+		"nickname":    role.Nickname,
+		"aliases":     "",
+		"prompt":      role.Prompt,
+	}
+	if !core.db.UpdateTopicConfig(chat.ID, chat.ThreadID, values) {
+		role, ok = core.db.SetRole(chat.ID, roleID)
+	}
+
+	core.StartChat(chat)
+	return role, ok
+}
+
+func (core *Core) ShouldReplyTo(fe Frontend, msg *Message) (Persona, bool, bool) {
+	persona, shouldReply, forceKeep, _ := core.decideReply(fe, msg)
+	return persona, shouldReply, forceKeep
+}
+
+func (core *Core) decideReply(fe Frontend, msg *Message) (Persona, bool, bool, bool) {
+	if len(msg.Text) == 0 || msg.Text[0] == '/' {
+		return Persona{}, false, false, false
+	}
+
+	cfg := core.LoadConfig(msg.Chat)
+	personas := core.loadPersonas(msg.Chat, cfg)
+	named, isNamed := findPersona(personas, msg.Text)
+
+	if msg.Chat.Private {
+		if isNamed {
+			return named, true, true, false
+		}
+
+		replied, ok := core.repliedPersona(msg.ReplyTo, personas)
+		if ok {
+			return replied, true, true, false
+		}
+
+		last, ok := core.lastPersona(msg.Chat, personas)
+		if ok {
+			return last, true, true, false
+		}
+
+		return pickPersona(personas), true, true, false
+	}
+
+	if len(msg.Text) > 1000 {
+		return Persona{}, false, false, false
+	}
+
+	if msg.ReplyTo != nil && msg.ReplyTo.SenderID == fe.BotID() {
+		if isNamed {
+			return named, true, true, false
+		}
+
+		replied, ok := core.repliedPersona(msg.ReplyTo, personas)
+		if ok {
+			return replied, true, true, false
+		}
+
+		return pickPersona(personas), true, true, false
+	}
+
+	if isNamed {
+		return named, true, false, false
+	}
+
+	if strings.Contains(msg.Text, fe.BotMention()) {
+		return pickPersona(personas), true, false, false
+	}
+
+	if cfg.Relevance > 0 {
+		persona, ok := core.chimeIn(msg, cfg, personas)
+		return persona, ok, false, ok
+	}
+
+	if rand.Intn(100) < cfg.Freq && totalWeight(personas) > 0 {
+		return pickPersona(personas), true, cfg.Freq == 100, true
+	}
+
+	return Persona{}, false, false, false
+}
+
+func (core *Core) ReadMessage(fe Frontend, msg *Message) (bool, error) {
+	core.noteActivity(fe, msg)
+	if core.readFlow(fe, msg) {
+		return true, nil
+	}
+
+	if !strings.HasPrefix(msg.Text, "/") {
+		sender := msg.SenderName
+		if sender == "" {
+			sender = "anonymous"
+		}
+		core.bufferMessage(msg.Chat, sender, msg.Text)
+	}
+
+	if core.mergeBurst(fe, msg) {
+		return true, nil
+	}
+
+	persona, shouldReply, forceKeepHistory, chimed := core.decideReply(fe, msg)
+	if !shouldReply {
+		return false, nil
+	}
+
+	text := core.userTurn(fe, msg)
+
+	if !core.allowReply(fe, msg, !chimed) {
+		return false, nil
+	}
+
+	key := persona.chatKey(msg.Chat)
+	if !core.ai.IsChatStarted(key) {
+		core.ai.StartChat(key, persona.Prompt, persona.MaxHistory)
+	}
+
+	b := core.startBurst(fe, msg, text)
+	for {
+		ctx, msg, text, n := core.burstTurn(b)
+
+		reply, sent, err := core.SendAiReply(ctx, fe, msg, persona, text, forceKeepHistory)
+		core.chargeQuota(msg.Chat, msg.SenderID, reply.CtxLen+reply.ReplyLen)
+		if err == nil && reply.Text != "" && chimed {
+			core.startCooldown(msg.Chat)
+		}
+		if err == nil && reply.Text != "" && msg.SenderID != fe.BotID() {
+			if n == 1 {
+				core.rememberTurn(msg, persona, reply, sent)
+			}
+			core.extractReminder(fe, msg, text)
+		}
+
+		if !core.finishTurn(b, n, reply.Text != "") {
+			return true, err
+		}
+	}
+}
+
+func (core *Core) ContinueReply(fe Frontend, msg *Message) error {
+	personas := core.loadPersonas(msg.Chat, core.LoadConfig(msg.Chat))
+	persona, ok := core.repliedPersona(msg, personas)
+	if !ok {
+		persona = personas[0]
+	}
+
+	if !core.ai.IsChatStarted(persona.chatKey(msg.Chat)) {
+		return nil
+	}
+
+	_, ok = core.checkQuota(msg.Chat, 0, time.Now())
+	if !ok {
+		return nil
+	}
+
+	reply, _, err := core.SendAiReply(context.Background(), fe, msg, persona, "continue", true)
+	core.chargeQuota(msg.Chat, 0, reply.CtxLen+reply.ReplyLen)
+
+	return err
+}
+
+func (core *Core) Welcome(fe Frontend, msg *Message) error {
+	core.StartChat(msg.Chat)
+
+	personas := core.loadPersonas(msg.Chat, core.LoadConfig(msg.Chat))
+	_, _, err := core.SendAiReply(context.Background(), fe, msg, pickPersona(personas), core.wlc, true)
+	return err
+}
+
+func (core *Core) SendAiReply(ctx context.Context, fe Frontend, msg *Message, persona Persona, userMsg string, isReply bool) (AIReply, *Message, error) {
+	err := fe.Typing(msg.Chat)
+	if err != nil {
+		core.log.Warnw(err.Error(), "chat_id", msg.Chat.ID)
+	}
+
+	ticker := time.NewTicker(time.Second * 3)
+	defer ticker.Stop()
+
+	var reply AIReply
+	job := core.scheduler.submit(msg.Chat, jobPriority(fe, msg), func() {
+		reply, _ = core.ai.GetReply(ctx, persona.chatKey(msg.Chat), userMsg, isReply)
+	})
+
+	notified := false
+	for {
+		select {
+		case <-ticker.C:
+			err = fe.Typing(msg.Chat)
+			if err != nil {
+				core.log.Warnw(err.Error(), "chat_id", msg.Chat.ID)
+			}
+
+			if !notified && msg.Chat.Private {
+				notified = core.sendQueuePosition(fe, msg, job)
+			}
+		case <-job.done:
+			sent, err := core.sendReply(fe, msg.Chat, msg, persona, reply)
+			return reply, sent, err
+		}
+	}
+}
+
+func (core *Core) sendQueuePosition(fe Frontend, msg *Message, job *genJob) bool {
+	pos := core.scheduler.position(job)
+	if pos == 0 {
+		return false
+	}
+
+	text := fmt.Sprintf("Many people are talking to me right now, "+
+		"there are %d messages ahead of yours. I'll answer soon.", pos)
+	_, err := fe.Send(msg.Chat, msg, text, SendOptions{})
+	if err != nil {
+		core.log.Warnw(err.Error(), "chat_id", msg.Chat.ID)
+	}
+
+	return true
+}
+
+func (core *Core) sendReply(fe Frontend, chat ChatRef, replyTo *Message, persona Persona, reply AIReply) (*Message, error) {
+	if reply.Text == "" {
+		return nil, nil
+	}
+
+	core.countReply(reply)
+
+	opts := SendOptions{
+		Markdown: true,
+		Continue: !reply.AtEnd,
+		Label:    persona.label(),
+	}
+	sent, err := fe.Send(chat, replyTo, reply.Text, opts)
+	if err == nil {
+		core.rememberPersona(sent, persona)
+		core.bufferMessage(chat, persona.senderName(), reply.Text)
+	}
+
+	return sent, err
+}
+
+func (core *Core) countReply(reply AIReply) {
+	core.aiMSgCount.Add(1)
+	core.aiMsgLength.Add(int64(reply.ReplyLen))
+	core.aiHstLength.Add(int64(reply.CtxLen))
+
+	zayaMetrics.outputMessages.Inc()
+	zayaMetrics.outputLength.Observe(float64(reply.ReplyLen))
+	zayaMetrics.inputContext.Observe(float64(reply.CtxLen))
+}
+
+func (core *Core) RunCommand(fe Frontend, msg *Message, cmd, arg string) (string, bool) {
+	chat := msg.Chat
+
+	if arg == "" {
+		text, ok := core.StartFlow(msg, cmd)
+		if ok {
+			return text, true
+		}
+	}
+
+	switch cmd {
+	case "cancel":
+		return core.CancelFlow(msg), true
+	case "settings":
+		panel, _ := core.Settings(fe, msg)
+		return panel.Text, true
+	case "restart_chat":
+		core.StartChat(chat)
+		return "Chat history cleared.", true
+	case "undo":
+		return core.Undo(msg, arg), true
+	case "export":
+		return core.Export(fe, msg, arg), true
+	case "import":
+		return core.Import(fe, msg, arg), true
+	case "checkpoint":
+		return core.Checkpoint(chat, arg), true
+	case "restore":
+		return core.Restore(chat, arg), true
+	case "select_role":
+		if arg == "" {
+			var text strings.Builder
+			for _, role := range core.db.LoadAllRoleNames(chat.ID) {
+				text.WriteString(fmt.Sprintf("%d: %s\n", role.ID, role.Name))
+			}
+			return strings.TrimSpace(text.String()), true
+		}
+
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			return "Example usage: select_role 1", true
+		}
+
+		role, ok := core.SetRole(chat, uint(id))
+		if !ok {
+			return "Can't select this role.", true
+		}
+
+		text := fmt.Sprintf("Now I'm acting as %s.", role.Name)
+		if role.Example != "" {
+			text += "\nTry send this message: " + role.Example
+		}
+		return text, true
+	case "save_role":
+		lang, name, _ := strings.Cut(arg, " ")
+		name = strings.TrimSpace(name)
+		if len(lang) != 2 || name == "" || len([]rune(name)) > 20 {
+			return "Example usage: save_role en Assistant", true
+		}
+
+		core.db.SaveRole(chat.ID, lang, name)
+		return "Role saved.", true
+	case "get_prompt":
+		return core.LoadConfig(chat).Prompt, true
+	case "get_max_history":
+		return core.MaxHistoryInfo(chat), true
+	case "set_max_history":
+		limit, err := strconv.Atoi(arg)
+		if err != nil || limit < 0 {
+			return "Example usage: set_max_history 10", true
+		}
+
+		core.SetMaxHistory(chat, limit)
+		return "History limit changed.", true
+	case "get_frequency":
+		return core.FrequencyInfo(chat), true
+	case "set_frequency":
+		freq, err := strconv.Atoi(arg)
+		if err != nil || freq < 0 || freq > 100 {
+			return "Example usage: set_frequency 10", true
+		}
+
+		core.SetFreq(chat, freq)
+		return "Frequency changed.", true
+	case "set_prompt":
+		return core.ChangePrompt(chat, arg), true
+	case "personas":
+		return core.PersonaList(chat), true
+	case "add_persona":
+		idStr, weightStr, _ := strings.Cut(arg, " ")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			return "Example usage: add_persona 1 [weight]", true
+		}
+
+		weight := 1
+		if weightStr != "" {
+			weight, err = strconv.Atoi(strings.TrimSpace(weightStr))
+			if err != nil || weight < 0 {
+				return "Example usage: add_persona 1 [weight]", true
+			}
+		}
+
+		role, ok := core.AddPersona(chat, uint(id), weight)
+		if !ok {
+			return "Can't add this persona.", true
+		}
+
+		return fmt.Sprintf("%s joined the chat. Call it %s.", role.Name, role.Nickname), true
+	case "remove_persona":
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			return "Example usage: remove_persona 1", true
+		}
+
+		if !core.RemovePersona(chat, uint(id)) {
+			return "Can't remove this persona.", true
+		}
+
+		return "Persona removed.", true
+	case "persona_weight":
+		weightStr, name, _ := strings.Cut(arg, " ")
+		weight, err := strconv.Atoi(weightStr)
+		if err != nil || weight < 0 || name == "" {
+			return "Example usage: persona_weight 3 Assistant", true
+		}
+
+		if !core.SetPersonaWeight(chat, strings.TrimSpace(name), weight) {
+			return "There is no such persona in this chat.", true
+		}
+
+		return "Persona weight changed.", true
+	case "persona_aliases":
+		if !core.SetPersonaAliases(chat, arg) {
+			return "" +
+				"Example usage: persona_aliases Lunatic luna, лунатик\n" +
+				"The first argument is the persona name, then go aliases separated with commas, " +
+				"- removes them.", true
+		}
+
+		return "Persona aliases changed.", true
+	case "debate":
+		return core.Debate(fe, msg, arg), true
+	case "stop_debate":
+		if !core.StopDebate(chat) {
+			return "There is no debate going on.", true
+		}
+
+		return "Stopping the debate.", true
+	case "get_initiative":
+		return core.InitiativeInfo(chat), true
+	case "set_initiative":
+		hours, err := strconv.Atoi(arg)
+		if err != nil || hours < 0 || hours > 24*7 {
+			return "Example usage: set_initiative 6", true
+		}
+
+		core.SetInitiative(chat, hours)
+		return "Initiative changed.", true
+	case "get_relevance":
+		return core.RelevanceInfo(chat), true
+	case "set_relevance":
+		threshold, err := strconv.Atoi(arg)
+		if err != nil || threshold < 0 || threshold > 100 {
+			return "" +
+				"Example usage: set_relevance 60\n" +
+				"I'll chime in only on messages scoring at least this much of 100, 0 brings back random replies.", true
+		}
+
+		core.SetRelevance(chat, threshold)
+		return "Relevance threshold changed.", true
+	case "summarize":
+		return core.Summarize(fe, msg, arg), true
+	case "quota":
+		return core.QuotaInfo(chat, msg.SenderID), true
+	case "get_model":
+		return core.ModelInfo(), true
+	case "set_limit":
+		if msg.SenderID != core.adminID || core.adminID == 0 {
+			return "Only the bot admin can change limits.", true
+		}
+
+		name, value, _ := strings.Cut(arg, " ")
+		if !core.SetChatLimit(chat, name, strings.TrimSpace(value)) {
+			return "Example usage: set_limit user_rate 2\n" +
+				"Limits: user_rate, user_burst, user_daily, chat_rate, chat_burst, chat_daily. " +
+				"Rates are messages per minute, daily limits are tokens, 0 means no limit, " +
+				"default restores the global value.", true
+		}
+
+		return "Limit changed.", true
+	case "remind":
+		return core.Remind(fe, msg, arg), true
+	case "reminders":
+		return core.ReminderList(chat), true
+	case "cancel_reminder":
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			return "Example usage: cancel_reminder 1", true
+		}
+
+		if !core.db.RemoveReminder(chat.ID, uint(id)) {
+			return "There is no such reminder.", true
+		}
+
+		return "Reminder cancelled.", true
+	case "get_timezone":
+		return "Current timezone: " + core.Location(chat).String(), true
+	case "set_timezone":
+		if !core.SetTimezone(chat, arg) {
+			return "Example usage: set_timezone Europe/Moscow", true
+		}
+
+		return "Timezone changed.", true
+	case "get_nickname":
+		aliases := configPersona(core.LoadConfig(chat)).aliases()
+		return fmt.Sprintf("You can call me %s.", strings.Join(aliases, ", ")), true
+	case "set_nickname":
+		if !core.SetNickname(chat, arg) {
+			return "" +
+				"Example usage: set_nickname zaya, зая, \"bot\"\n" +
+				"Separate aliases with commas. Case forms like заю or заей match too, " +
+				"put an alias in quotes to match it exactly.", true
+		}
+
+		return "Nickname changed.", true
+	case "test_trigger":
+		return core.TestTrigger(fe, chat, arg), true
+	}
+
+	return "", false
+}
+
+func (core *Core) MaxHistoryInfo(chat ChatRef) string {
+	maxHistory := core.LoadConfig(chat).MaxHistory
+	if maxHistory > 0 {
+		return fmt.Sprintf("My conversational memory will be capped at max %d preceding messages.", maxHistory)
+	}
+
+	return "" +
+		"My conversational memory will be unlimited, " +
+		"retaining all preceding messages within my capabilities."
+}
+
+func (core *Core) FrequencyInfo(chat ChatRef) string {
+	freq := core.LoadConfig(chat).Freq
+	return fmt.Sprintf("I will respond to a percentage of %d%% random messages in the chat.", freq)
+}
+
+func (core *Core) ModelInfo() string {
+	const msgTxt = "" +
+		"I am currently utilizing the %s model.\n" +
+		"The primary model is a more recent and sophisticated iteration of the LLM, " +
+		"albeit with certain usage limits. Occasionally, in the event of exceeding " +
+		"the allocated quota, I can seamlessly transition to the auxiliary model. " +
+		"These limitations are applied to all users collectively, so in addition " +
+		"each user and each chat has its own message rate and daily token quota, " +
+		"the quota command shows what is left. " +
+		"While the auxiliary model may not possess the same level of capabilities " +
+		"as its primary counterpart, it is more than adequate for the vast majority of tasks."
+
+	model := "primary"
+	if core.ai.IsAltModel() {
+		model = "auxiliary"
+	}
+
+	return fmt.Sprintf(msgTxt, model)
+}
+
+func (core *Core) GetStat() string {
+	var msg strings.Builder
+
+	addF64 := func(title string, value float64) {
+		if math.IsInf(value, 0) || math.IsNaN(value) {
+			value = 0
+		}
+
+		msg.WriteString(fmt.Sprintf("%s: %.2f\n", title, value))
+	}
+
+	addI64 := func(title string, value int64) {
+		msg.WriteString(fmt.Sprintf("%s: %d\n", title, value))
+	}
+
+	uptimeDays := time.Now().Sub(core.startedAt).Hours() / 24
+	addF64("Uptime (days)", uptimeDays)
+
+	totalMsgCnt := core.aiMSgCount.Load()
+	addI64("Total count of output messages", totalMsgCnt)
+
+	totalMsgLen := core.aiMsgLength.Load()
+	addI64("Total length of output messages (KiB)", totalMsgLen/1024)
+
+	if uptimeDays > 0.1 {
+		avgMsgCnt := float64(totalMsgCnt) / uptimeDays
+		addF64("Count of output messages per day", avgMsgCnt)
+
+		avgMsgLen := float64(totalMsgLen) / 1024 / uptimeDays
+		addF64("Length of output messages per day (KiB)", avgMsgLen)
+
+		avgHstLen := float64(core.aiHstLength.Load()) / 1024 / uptimeDays
+		addF64("Length of input context per day (KiB)", avgHstLen)
+	}
+
+	groupChatCnt := core.db.GetChatCount()
+	addI64("Total count of chats", groupChatCnt)
+
+	customRoleCnt := core.db.GetCustomRoleCount()
+	addI64("Count of custom roles", customRoleCnt)
+
+	return msg.String()
+}
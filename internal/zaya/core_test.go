@@ -0,0 +1,377 @@
+package zaya
+
+import (
+	"context"
+	"github.com/erni27/imcache"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+	"go.uber.org/zap/zaptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testLLM struct {
+	lastMessages []llms.MessageContent
+	respond      func(prompt, text string) string
+}
+
+func (llm *testLLM) GenerateContent(_ context.Context, messages []llms.MessageContent, _ ...llms.CallOption) (*llms.ContentResponse, error) {
+	llm.lastMessages = messages
+	prompt := messages[0].Parts[0].(llms.TextContent).Text
+	text := messages[len(messages)-1].Parts[0].(llms.TextContent).Text
+
+	content := "re: " + text
+	if llm.respond != nil {
+		content = llm.respond(prompt, text)
+	}
+
+	return &llms.ContentResponse{
+		Choices: []*llms.ContentChoice{
+			{Content: content, StopReason: "stop"},
+		},
+	}, nil
+}
+
+func (llm *testLLM) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, llm, prompt, options...)
+}
+
+func setupTestAI(t *testing.T) (*AI, *testLLM) {
+	llm := &testLLM{}
+	ai := &AI{
+		llm:     llm,
+		altLlm:  llm,
+		log:     zaptest.NewLogger(t).Sugar(),
+		maxCtx:  1000,
+		maxTok:  100,
+		maxDur:  time.Hour,
+		chatExp: imcache.WithNoExpiration(),
+	}
+
+	return ai, llm
+}
+
+type testFrontend struct {
+	lock   sync.Mutex
+	sent   []*Message
+	edited []*Message
+	files  map[string][]byte
+	admins map[int64]bool
+	err    error
+}
+
+func (fe *testFrontend) Name() string       { return "test" }
+func (fe *testFrontend) BotID() int64       { return 100 }
+func (fe *testFrontend) BotMention() string { return "@test_bot" }
+func (fe *testFrontend) Typing(ChatRef) error {
+	return nil
+}
+
+func (fe *testFrontend) Send(chat ChatRef, _ *Message, text string, opts SendOptions) (*Message, error) {
+	fe.lock.Lock()
+	defer fe.lock.Unlock()
+
+	if fe.err != nil {
+		return nil, fe.err
+	}
+
+	opts.Markdown = false
+	msg := &Message{
+		ID:       strconv.Itoa(len(fe.sent) + 1),
+		Chat:     chat,
+		SenderID: fe.BotID(),
+		Text:     labelText(text, opts),
+	}
+	fe.sent = append(fe.sent, msg)
+	return msg, nil
+}
+
+func (fe *testFrontend) sentTexts() []string {
+	fe.lock.Lock()
+	defer fe.lock.Unlock()
+
+	texts := make([]string, 0, len(fe.sent))
+	for _, msg := range fe.sent {
+		texts = append(texts, msg.Text)
+	}
+
+	return texts
+}
+
+func (fe *testFrontend) SendFile(_ ChatRef, _ *Message, name string, data []byte) error {
+	fe.lock.Lock()
+	defer fe.lock.Unlock()
+
+	if fe.files == nil {
+		fe.files = make(map[string][]byte)
+	}
+	fe.files[name] = data
+	return nil
+}
+
+func (fe *testFrontend) IsChatAdmin(_ ChatRef, userID int64) bool {
+	return fe.admins[userID]
+}
+
+func (fe *testFrontend) Edit(msg *Message, text string, _ SendOptions) error {
+	msg.Text = text
+	fe.edited = append(fe.edited, msg)
+	return nil
+}
+
+func setupTestCore(t *testing.T) (*Core, *testLLM) {
+	ai, llm := setupTestAI(t)
+	db := setupTestDB(t)
+	core := NewCore(ai, db, Config{Welcome: "welcome"})
+
+	return core, llm
+}
+
+func TestShouldReplyTo(t *testing.T) {
+	core, _ := setupTestCore(t)
+	fe := &testFrontend{}
+	group := ChatRef{ID: -1}
+	core.db.SetFreq(group.ID, 0)
+
+	tests := []struct {
+		name      string
+		msg       *Message
+		reply     bool
+		forceKeep bool
+	}{
+		{
+			name:      "Private chat",
+			msg:       &Message{Chat: ChatRef{ID: 1, Private: true}, Text: "hi"},
+			reply:     true,
+			forceKeep: true,
+		},
+		{
+			name: "Command",
+			msg:  &Message{Chat: ChatRef{ID: 1, Private: true}, Text: "/help"},
+		},
+		{
+			name: "Random group message",
+			msg:  &Message{Chat: group, Text: "hi all"},
+		},
+		{
+			name:      "Reply to bot",
+			msg:       &Message{Chat: group, Text: "hi", ReplyTo: &Message{SenderID: 100}},
+			reply:     true,
+			forceKeep: true,
+		},
+		{
+			name:  "Mention",
+			msg:   &Message{Chat: group, Text: "hi @test_bot"},
+			reply: true,
+		},
+		{
+			name:  "Nickname",
+			msg:   &Message{Chat: group, Text: "Hi Test"},
+			reply: true,
+		},
+		{
+			name: "Too long",
+			msg:  &Message{Chat: group, Text: "test " + strings.Repeat("a", 1000)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, reply, forceKeep := core.ShouldReplyTo(fe, tt.msg)
+			require.Equal(t, tt.reply, reply)
+			require.Equal(t, tt.forceKeep, forceKeep)
+		})
+	}
+}
+
+func TestCommandHelp(t *testing.T) {
+	core, _ := setupTestCore(t)
+	fe := &testFrontend{}
+
+	help := core.CommandHelp("!")
+	for _, name := range []string{"get_max_history", "get_frequency", "get_model", "undo", "cancel"} {
+		require.Contains(t, help, "!"+name+" ")
+	}
+	require.Contains(t, help, "!undo [n] - ")
+	require.NotContains(t, help, "!start")
+	require.NotContains(t, help, "!notify")
+	require.NotContains(t, help, "!set_limit")
+
+	msg := &Message{Chat: ChatRef{ID: 1, Private: true}, SenderID: 1}
+	for _, cmd := range commandSpecs {
+		if cmd.telegramOnly || cmd.name == "help" || !strings.Contains(help, "!"+cmd.name+" ") {
+			continue
+		}
+
+		_, ok := core.RunCommand(fe, msg, cmd.name, "")
+		require.True(t, ok, cmd.name)
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	core, llm := setupTestCore(t)
+	fe := &testFrontend{}
+	chat := ChatRef{ID: 1, Private: true}
+
+	replied, err := core.ReadMessage(fe, &Message{Chat: chat, Text: "hello"})
+	require.NoError(t, err)
+	require.True(t, replied)
+	require.Len(t, fe.sent, 1)
+	require.Equal(t, "re: hello", fe.sent[0].Text)
+	require.Len(t, llm.lastMessages, 2)
+	require.Equal(t, defaultCfg.Prompt, llm.lastMessages[0].Parts[0].(llms.TextContent).Text)
+
+	group := ChatRef{ID: -1}
+	quoted := &Message{ID: "10", Chat: group, SenderID: 5, SenderName: "alice", Text: "quoted text"}
+	replied, err = core.ReadMessage(fe, &Message{ID: "11", Chat: group, SenderID: 6, Text: "@test_bot", ReplyTo: quoted})
+	require.NoError(t, err)
+	require.True(t, replied)
+	require.Equal(t, "re: > alice: quoted text", fe.sent[1].Text)
+
+	turn, ok := core.turns.Get(group.Key())
+	require.True(t, ok)
+	require.Equal(t, "11", turn.msgID)
+}
+
+func TestTopicConversations(t *testing.T) {
+	core, llm := setupTestCore(t)
+	fe := &testFrontend{}
+	general := ChatRef{ID: -1}
+	topic := ChatRef{ID: -1, ThreadID: 7}
+
+	core.db.CreateTopicConfig(topic.ID, topic.ThreadID)
+	core.SetPrompt(topic, "topic prompt")
+	require.Equal(t, defaultCfg.Prompt, core.LoadConfig(general).Prompt)
+	require.Equal(t, "topic prompt", core.LoadConfig(topic).Prompt)
+
+	_, err := core.ReadMessage(fe, &Message{Chat: general, Text: "test one"})
+	require.NoError(t, err)
+	_, err = core.ReadMessage(fe, &Message{Chat: topic, Text: "test two"})
+	require.NoError(t, err)
+	require.Len(t, llm.lastMessages, 2)
+	require.Equal(t, "topic prompt", llm.lastMessages[0].Parts[0].(llms.TextContent).Text)
+
+	require.Equal(t, topic, fe.sent[1].Chat)
+
+	messages := core.ai.GetAllMessages()
+	require.Len(t, messages, 6)
+
+	core.ai.chats.RemoveAll()
+	maxHst, ok := core.db.LoadMaxHistory()
+	require.True(t, ok)
+	core.ai.AddAllMessages(messages, maxHst)
+	require.True(t, core.ai.IsChatStarted(general.Key()))
+	require.True(t, core.ai.IsChatStarted(topic.Key()))
+
+	core.SetNickname(topic, "topicnick")
+	require.Equal(t, "topicnick", core.LoadConfig(topic).Nickname)
+	require.Equal(t, defaultCfg.Nickname, core.LoadConfig(general).Nickname)
+}
+
+func TestPersonas(t *testing.T) {
+	core, llm := setupTestCore(t)
+	fe := &testFrontend{}
+	group := ChatRef{ID: -1}
+	core.db.SetFreq(group.ID, 0)
+
+	core.db.SaveRole(group.ID, "en", "Socrat")
+	core.db.SetNickname(group.ID, "lunatic")
+	core.db.SetPrompt(group.ID, "lunatic prompt")
+	core.db.SaveRole(group.ID, "en", "Lunatic")
+	roles := core.db.LoadChatRoleNames(group.ID)
+	require.Len(t, roles, 2)
+
+	lunatic, socrat := roles[0], roles[1]
+	_, ok := core.AddPersona(group, socrat.ID, 1)
+	require.True(t, ok)
+	_, ok = core.AddPersona(group, lunatic.ID, 0)
+	require.True(t, ok)
+	_, ok = core.AddPersona(ChatRef{ID: -2}, socrat.ID, 1)
+	require.False(t, ok)
+
+	require.Contains(t, core.PersonaList(group), "Lunatic (nickname lunatic, weight 0)")
+
+	persona, reply, _ := core.ShouldReplyTo(fe, &Message{Chat: group, Text: "hey Lunatic"})
+	require.True(t, reply)
+	require.Equal(t, lunatic.ID, persona.RoleID)
+
+	persona, reply, _ = core.ShouldReplyTo(fe, &Message{Chat: group, Text: "hey @test_bot"})
+	require.True(t, reply)
+	require.Equal(t, socrat.ID, persona.RoleID)
+
+	_, err := core.ReadMessage(fe, &Message{Chat: group, Text: "lunatic, how are you?"})
+	require.NoError(t, err)
+	require.Equal(t, "Lunatic:\nre: lunatic, how are you?", fe.sent[0].Text)
+	require.Equal(t, "lunatic prompt", llm.lastMessages[0].Parts[0].(llms.TextContent).Text)
+
+	persona, reply, forceKeep := core.ShouldReplyTo(fe, &Message{Chat: group, Text: "why?", ReplyTo: fe.sent[0]})
+	require.True(t, reply)
+	require.True(t, forceKeep)
+	require.Equal(t, lunatic.ID, persona.RoleID)
+
+	require.True(t, core.SetPersonaWeight(group, "lunatic", 5))
+	require.False(t, core.SetPersonaWeight(group, "nobody", 5))
+
+	text, _ := core.RunCommand(fe, &Message{Chat: group}, "persona_aliases", "Lunatic luna, мечтатель")
+	require.Equal(t, "Persona aliases changed.", text)
+	require.Contains(t, core.PersonaList(group), "also called luna, мечтатель")
+	persona, reply, _ = core.ShouldReplyTo(fe, &Message{Chat: group, Text: "luna, what's up?"})
+	require.True(t, reply)
+	require.Equal(t, lunatic.ID, persona.RoleID)
+
+	text, _ = core.RunCommand(fe, &Message{Chat: group}, "persona_aliases", "Nobody luna")
+	require.True(t, strings.HasPrefix(text, "Example usage"), text)
+	require.True(t, core.SetPersonaAliases(group, "lunatic -"))
+	require.NotContains(t, core.PersonaList(group), "also called")
+
+	require.True(t, core.RemovePersona(group, lunatic.ID))
+	require.False(t, core.RemovePersona(group, lunatic.ID))
+
+	_, reply, _ = core.ShouldReplyTo(fe, &Message{Chat: group, Text: "hey lunatic"})
+	require.False(t, reply)
+}
+
+func TestPickPersona(t *testing.T) {
+	personas := []Persona{
+		{RoleID: 1, Weight: 0},
+		{RoleID: 2, Weight: 3},
+	}
+
+	for i := 0; i < 20; i++ {
+		require.Equal(t, uint(2), pickPersona(personas).RoleID)
+	}
+
+	personas[1].Weight = 0
+	require.Equal(t, 0, totalWeight(personas))
+	require.Contains(t, []uint{1, 2}, pickPersona(personas).RoleID)
+}
+
+func TestPrivatePersonaSticks(t *testing.T) {
+	core, _ := setupTestCore(t)
+	fe := &testFrontend{}
+	chat := ChatRef{ID: 1, Private: true}
+
+	core.db.SetPrompt(chat.ID, "socrat prompt")
+	core.db.SaveRole(chat.ID, "en", "Socrat")
+	core.db.SetNickname(chat.ID, "lunatic")
+	core.db.SetPrompt(chat.ID, "lunatic prompt")
+	core.db.SaveRole(chat.ID, "en", "Lunatic")
+	roles := core.db.LoadChatRoleNames(chat.ID)
+	lunatic, socrat := roles[0], roles[1]
+	_, ok := core.AddPersona(chat, socrat.ID, 1)
+	require.True(t, ok)
+	_, ok = core.AddPersona(chat, lunatic.ID, 1)
+	require.True(t, ok)
+
+	_, err := core.ReadMessage(fe, &Message{ID: "1", Chat: chat, SenderID: 1, Text: "lunatic, hi"})
+	require.NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		persona, reply, _ := core.ShouldReplyTo(fe, &Message{Chat: chat, SenderID: 1, Text: "and then?"})
+		require.True(t, reply)
+		require.Equal(t, lunatic.ID, persona.RoleID)
+	}
+}
@@ -1,6 +1,7 @@
 package zaya
 
 import (
+	"context"
 	"database/sql"
 	"github.com/glebarez/sqlite"
 	"github.com/knadh/koanf/parsers/toml"
@@ -8,6 +9,7 @@ import (
 	"github.com/knadh/koanf/v2"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"time"
 )
 
@@ -22,12 +24,31 @@ type ChatConfig struct {
 	Freq       int
 	MaxHistory int
 	Nickname   string
+	Aliases    string
 	Prompt     string
+	Initiative int
+	Relevance  int
+	Timezone   string
+	Lang       string
 	CreatedAt  time.Time
 	UpdatedAt  time.Time
 	DeletedAt  gorm.DeletedAt
 }
 
+type TopicConfig struct {
+	ChatID     int64 `gorm:"primaryKey;autoIncrement:false"`
+	ThreadID   int   `gorm:"primaryKey;autoIncrement:false"`
+	Freq       int
+	MaxHistory int
+	Nickname   string
+	Aliases    string
+	Prompt     string
+	Initiative int
+	Relevance  int
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
 type BotRole struct {
 	gorm.Model
 	ChatID     int64
@@ -45,8 +66,89 @@ type BotRoleList struct {
 
 type DialogMessage struct {
 	gorm.Model
-	ChatID int64
-	Text   string
+	ChatID   int64
+	ThreadID int
+	RoleID   uint
+	Text     string
+}
+
+type ChatPersona struct {
+	ChatID    int64 `gorm:"primaryKey;autoIncrement:false"`
+	ThreadID  int   `gorm:"primaryKey;autoIncrement:false"`
+	RoleID    uint  `gorm:"primaryKey;autoIncrement:false"`
+	Weight    int
+	Aliases   string
+	CreatedAt time.Time
+}
+
+type Persona struct {
+	RoleID     uint
+	Name       string
+	Nickname   string
+	Aliases    string
+	Prompt     string
+	MaxHistory int
+	Weight     int
+}
+
+type Reminder struct {
+	gorm.Model
+	ChatID   int64 `gorm:"index"`
+	ThreadID int
+	Frontend string
+	UserID   int64
+	UserName string
+	Text     string
+	DueAt    time.Time `gorm:"index"`
+	Attempts int
+	RetryAt  time.Time
+}
+
+type ChatLimit struct {
+	ChatID    int64 `gorm:"primaryKey;autoIncrement:false"`
+	UserRate  *float64
+	UserBurst *int
+	UserDaily *int
+	ChatRate  *float64
+	ChatBurst *int
+	ChatDaily *int
+	UpdatedAt time.Time
+}
+
+type UsageRecord struct {
+	Subject  string `gorm:"primaryKey"`
+	Day      string
+	Tokens   int
+	Bucket   float64
+	BucketAt time.Time
+}
+
+type Checkpoint struct {
+	ChatID    int64  `gorm:"primaryKey;autoIncrement:false"`
+	ThreadID  int    `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"primaryKey"`
+	History   string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type ChatActivity struct {
+	ChatID     int64 `gorm:"primaryKey;autoIncrement:false"`
+	ThreadID   int   `gorm:"primaryKey;autoIncrement:false"`
+	Frontend   string
+	LastTime   time.Time
+	Day        string
+	SentToday  int
+	Unanswered int
+}
+
+const externalChatIDBase int64 = 1 << 56
+
+type ExternalChat struct {
+	ID         int64  `gorm:"primaryKey"`
+	Frontend   string `gorm:"uniqueIndex:idx_external_chat"`
+	ExternalID string `gorm:"uniqueIndex:idx_external_chat"`
+	CreatedAt  time.Time
 }
 
 func LoadDatabase(path string, defaultCfg ChatConfig) (*DB, bool) {
@@ -57,7 +159,9 @@ func LoadDatabase(path string, defaultCfg ChatConfig) (*DB, bool) {
 		return nil, false
 	}
 
-	err = db.AutoMigrate(&ChatConfig{}, &BotRole{}, &DialogMessage{})
+	err = db.AutoMigrate(&ChatConfig{}, &TopicConfig{}, &BotRole{}, &DialogMessage{}, &ExternalChat{},
+		&ChatPersona{}, &Reminder{}, &ChatLimit{}, &UsageRecord{}, &Checkpoint{},
+		&ChatActivity{})
 	if err != nil {
 		log.Error(err)
 		return nil, false
@@ -135,9 +239,82 @@ func (db *DB) LoadChatConfig(chatID int64) *ChatConfig {
 	return &cfg
 }
 
+func (db *DB) LoadTopicConfig(chatID int64, threadID int) (*ChatConfig, bool) {
+	if threadID == 0 {
+		return db.LoadChatConfig(chatID), false
+	}
+
+	var topic TopicConfig
+	db.db.
+		Where("chat_id = ?", chatID).
+		Where("thread_id = ?", threadID).
+		Limit(1).
+		Find(&topic)
+
+	if topic.ChatID != chatID {
+		return db.LoadChatConfig(chatID), false
+	}
+
+	return &ChatConfig{
+		ChatID:     chatID,
+		Freq:       topic.Freq,
+		MaxHistory: topic.MaxHistory,
+		Nickname:   topic.Nickname,
+		Aliases:    topic.Aliases,
+		Prompt:     topic.Prompt,
+		Initiative: topic.Initiative,
+		Relevance:  topic.Relevance,
+		CreatedAt:  topic.CreatedAt,
+		UpdatedAt:  topic.UpdatedAt,
+	}, true
+}
+
+func (db *DB) CreateTopicConfig(chatID int64, threadID int) {
+	cfg := db.LoadChatConfig(chatID)
+
+	topic := &TopicConfig{
+		ChatID:     chatID,
+		ThreadID:   threadID,
+		Freq:       cfg.Freq,
+		MaxHistory: cfg.MaxHistory,
+		Nickname:   cfg.Nickname,
+		Aliases:    cfg.Aliases,
+		Prompt:     cfg.Prompt,
+		Initiative: cfg.Initiative,
+		Relevance:  cfg.Relevance,
+	}
+
+	err := db.db.Clauses(clause.OnConflict{DoNothing: true}).Create(topic).Error
+	if err != nil {
+		db.log.Warnw(err.Error(), "chat_id", chatID, "thread_id", threadID)
+	}
+}
+
+func (db *DB) RemoveTopicConfig(chatID int64, threadID int) bool {
+	tx := db.db.
+		Where("chat_id = ?", chatID).
+		Where("thread_id = ?", threadID).
+		Delete(&TopicConfig{})
+
+	return tx.RowsAffected > 0
+}
+
+func (db *DB) UpdateTopicConfig(chatID int64, threadID int, values map[string]any) bool {
+	if threadID == 0 {
+		return false
+	}
+
+	tx := db.db.Model(&TopicConfig{}).
+		Where("chat_id = ?", chatID).
+		Where("thread_id = ?", threadID).
+		Updates(values)
+
+	return tx.RowsAffected > 0
+}
+
 func (db *DB) SetFreq(chatID int64, freq int) {
 	tx := db.db.Model(&ChatConfig{}).Where(chatID).
-		Updates(&ChatConfig{Freq: freq})
+		Updates(map[string]any{"freq": freq})
 
 	if tx.RowsAffected < 1 {
 		cfg := db.cfg
@@ -159,6 +336,18 @@ func (db *DB) SetNickname(chatID int64, nickname string) {
 	}
 }
 
+func (db *DB) SetAliases(chatID int64, aliases string) {
+	tx := db.db.Model(&ChatConfig{}).Where(chatID).
+		Updates(map[string]any{"aliases": aliases})
+
+	if tx.RowsAffected < 1 {
+		cfg := db.cfg
+		cfg.ChatID = chatID
+		cfg.Aliases = aliases
+		db.db.Create(&cfg)
+	}
+}
+
 func (db *DB) SetPrompt(chatID int64, prompt string) {
 	tx := db.db.Model(&ChatConfig{}).Where(chatID).
 		Updates(&ChatConfig{Prompt: prompt})
@@ -185,6 +374,54 @@ func (db *DB) SetMaxHistory(chatID int64, maxHistory int) {
 	}
 }
 
+func (db *DB) SetInitiative(chatID int64, hours int) {
+	tx := db.db.Model(&ChatConfig{}).Where(chatID).
+		Updates(map[string]any{"initiative": hours})
+
+	if tx.RowsAffected < 1 {
+		cfg := db.cfg
+		cfg.ChatID = chatID
+		cfg.Initiative = hours
+		db.db.Create(&cfg)
+	}
+}
+
+func (db *DB) SetRelevance(chatID int64, threshold int) {
+	tx := db.db.Model(&ChatConfig{}).Where(chatID).
+		Updates(map[string]any{"relevance": threshold})
+
+	if tx.RowsAffected < 1 {
+		cfg := db.cfg
+		cfg.ChatID = chatID
+		cfg.Relevance = threshold
+		db.db.Create(&cfg)
+	}
+}
+
+func (db *DB) SetTimezone(chatID int64, timezone string) {
+	tx := db.db.Model(&ChatConfig{}).Where(chatID).
+		Updates(map[string]any{"timezone": timezone})
+
+	if tx.RowsAffected < 1 {
+		cfg := db.cfg
+		cfg.ChatID = chatID
+		cfg.Timezone = timezone
+		db.db.Create(&cfg)
+	}
+}
+
+func (db *DB) SetLang(chatID int64, lang string) {
+	tx := db.db.Model(&ChatConfig{}).Where(chatID).
+		Updates(map[string]any{"lang": lang})
+
+	if tx.RowsAffected < 1 {
+		cfg := db.cfg
+		cfg.ChatID = chatID
+		cfg.Lang = lang
+		db.db.Create(&cfg)
+	}
+}
+
 func clampMaxHistory(maxHistory int) int {
 	if maxHistory == 0 {
 		maxHistory = 50
@@ -199,7 +436,7 @@ func (db *DB) LoadAllRoleNames(chatID int64) []BotRole {
 	var roles []BotRole
 
 	db.db.
-		Select("id, name").
+		Select("id, name, lang").
 		Where("chat_id = 0").
 		Or("chat_id = ?", chatID).
 		Order("lang asc").
@@ -261,20 +498,56 @@ func (db *DB) RemoveRole(chatID int64, roleID uint) bool {
 	return tx.RowsAffected > 0
 }
 
-func (db *DB) SetRole(chatID int64, roleID uint) (*BotRole, bool) {
+func (db *DB) LoadRole(chatID int64, roleID uint) (*BotRole, bool) {
 	role := &BotRole{}
 
-	db.db.First(role, roleID)
+	db.db.Limit(1).Find(role, roleID)
 
 	if role.ID == 0 || (role.ChatID != 0 && role.ChatID != chatID) {
 		return nil, false
 	}
 
+	return role, true
+}
+
+func (db *DB) LoadRoleLangs(chatID int64) []string {
+	var langs []string
+
+	db.db.
+		Model(&BotRole{}).
+		Distinct("lang").
+		Where("chat_id = 0 OR chat_id = ?", chatID).
+		Order("lang asc").
+		Pluck("lang", &langs)
+
+	return langs
+}
+
+func (db *DB) FindRoleByPrompt(chatID int64, prompt string) (*BotRole, bool) {
+	role := &BotRole{}
+
+	db.db.
+		Where("chat_id = 0 OR chat_id = ?", chatID).
+		Where("prompt = ?", prompt).
+		Order("chat_id desc").
+		Limit(1).
+		Find(role)
+
+	return role, role.ID != 0
+}
+
+func (db *DB) SetRole(chatID int64, roleID uint) (*BotRole, bool) {
+	role, ok := db.LoadRole(chatID, roleID)
+	if !ok {
+		return nil, false
+	}
+
 	tx := db.db.Model(&ChatConfig{}).Where(chatID).
-		Updates(&ChatConfig{
-			MaxHistory: role.MaxHistory,
-			Nickname:   role.Nickname,
-			Prompt:     role.Prompt,
+		Updates(map[string]any{
+			"max_history": role.MaxHistory,
+			"nickname":    role.Nickname,
+			"aliases":     "",
+			"prompt":      role.Prompt,
 		})
 
 	if tx.RowsAffected < 1 {
@@ -316,20 +589,181 @@ func (db *DB) SaveMessages(messages []DialogMessage) {
 	}
 }
 
-func (db *DB) LoadMaxHistory() (map[int64]int, bool) {
+func (db *DB) SaveActivity(activity []ChatActivity) {
+	if len(activity) == 0 {
+		return
+	}
+
+	err := db.db.Save(activity).Error
+	if err != nil {
+		db.log.Warnw(err.Error())
+	}
+}
+
+func (db *DB) LoadActivity() []ChatActivity {
+	var activity []ChatActivity
+
+	err := db.db.Find(&activity).Error
+	if err != nil {
+		db.log.Warnw(err.Error())
+	}
+
+	return activity
+}
+
+func (db *DB) LoadMaxHistory() (map[ChatKey]int, bool) {
 	configs := make([]*ChatConfig, 0)
 
 	err := db.db.Find(&configs).Error
 	if err != nil {
 		db.log.Warnw(err.Error())
+		return nil, false
+	}
+
+	topics := make([]*TopicConfig, 0)
+
+	err = db.db.Find(&topics).Error
+	if err != nil {
+		db.log.Warnw(err.Error())
+		return nil, false
 	}
 
-	maxHst := make(map[int64]int)
+	maxHst := make(map[ChatKey]int)
 	for _, cfg := range configs {
-		maxHst[cfg.ChatID] = cfg.MaxHistory
+		maxHst[ChatKey{ChatID: cfg.ChatID}] = cfg.MaxHistory
+	}
+	for _, topic := range topics {
+		maxHst[ChatKey{ChatID: topic.ChatID, ThreadID: topic.ThreadID}] = topic.MaxHistory
+	}
+
+	personas := make([]*ChatPersona, 0)
+
+	err = db.db.Find(&personas).Error
+	if err != nil {
+		db.log.Warnw(err.Error())
+		return nil, false
+	}
+
+	roles := db.loadRolesByID(personas)
+	for _, persona := range personas {
+		role, ok := roles[persona.RoleID]
+		if ok {
+			key := ChatKey{ChatID: persona.ChatID, ThreadID: persona.ThreadID, RoleID: persona.RoleID}
+			maxHst[key] = role.MaxHistory
+		}
+	}
+
+	return maxHst, true
+}
+
+func (db *DB) loadRolesByID(personas []*ChatPersona) map[uint]*BotRole {
+	roles := make(map[uint]*BotRole, len(personas))
+	if len(personas) == 0 {
+		return roles
+	}
+
+	roleIDs := make([]uint, 0, len(personas))
+	for _, persona := range personas {
+		roleIDs = append(roleIDs, persona.RoleID)
+	}
+
+	loaded := make([]*BotRole, 0, len(roleIDs))
+	err := db.db.Find(&loaded, roleIDs).Error
+	if err != nil {
+		db.log.Warnw(err.Error())
+	}
+
+	for _, role := range loaded {
+		roles[role.ID] = role
+	}
+
+	return roles
+}
+
+func (db *DB) AddPersona(chatID int64, threadID int, roleID uint, weight int) (*BotRole, bool) {
+	role, ok := db.LoadRole(chatID, roleID)
+	if !ok {
+		return nil, false
+	}
+
+	persona := &ChatPersona{
+		ChatID:   chatID,
+		ThreadID: threadID,
+		RoleID:   roleID,
+		Weight:   weight,
 	}
 
-	return maxHst, err == nil
+	err := db.db.Save(persona).Error
+	if err != nil {
+		db.log.Warnw(err.Error(), "chat_id", chatID)
+		return nil, false
+	}
+
+	return role, true
+}
+
+func (db *DB) RemovePersona(chatID int64, threadID int, roleID uint) bool {
+	tx := db.db.
+		Where("chat_id = ?", chatID).
+		Where("thread_id = ?", threadID).
+		Where("role_id = ?", roleID).
+		Delete(&ChatPersona{})
+
+	return tx.RowsAffected > 0
+}
+
+func (db *DB) SetPersonaWeight(chatID int64, threadID int, roleID uint, weight int) bool {
+	tx := db.db.Model(&ChatPersona{}).
+		Where("chat_id = ?", chatID).
+		Where("thread_id = ?", threadID).
+		Where("role_id = ?", roleID).
+		Update("weight", weight)
+
+	return tx.RowsAffected > 0
+}
+
+func (db *DB) SetPersonaAliases(chatID int64, threadID int, roleID uint, aliases string) bool {
+	tx := db.db.Model(&ChatPersona{}).
+		Where("chat_id = ?", chatID).
+		Where("thread_id = ?", threadID).
+		Where("role_id = ?", roleID).
+		Update("aliases", aliases)
+
+	return tx.RowsAffected > 0
+}
+
+func (db *DB) LoadPersonas(chatID int64, threadID int) []Persona {
+	personas := make([]*ChatPersona, 0)
+
+	err := db.db.
+		Where("chat_id = ?", chatID).
+		Where("thread_id = ?", threadID).
+		Order("created_at asc").
+		Find(&personas).Error
+	if err != nil {
+		db.log.Warnw(err.Error(), "chat_id", chatID)
+	}
+
+	roles := db.loadRolesByID(personas)
+	result := make([]Persona, 0, len(personas))
+	for _, persona := range personas {
+		role, ok := roles[persona.RoleID]
+		if !ok {
+			continue
+		}
+
+		result = append(result, Persona{
+			RoleID:     role.ID,
+			Name:       role.Name,
+			Nickname:   role.Nickname,
+			Aliases:    persona.Aliases,
+			Prompt:     role.Prompt,
+			MaxHistory: role.MaxHistory,
+			Weight:     persona.Weight,
+		})
+	}
+
+	return result
 }
 
 func (db *DB) LoadMessages() ([]DialogMessage, bool) {
@@ -343,6 +777,24 @@ func (db *DB) LoadMessages() ([]DialogMessage, bool) {
 	return messages, err == nil
 }
 
+func (db *DB) Ping() bool {
+	sqlDB, err := db.db.DB()
+	if err != nil {
+		db.log.Warnw(err.Error())
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err = sqlDB.PingContext(ctx)
+	if err != nil {
+		db.log.Warnw(err.Error())
+	}
+
+	return err == nil
+}
+
 func (db *DB) GetChatCount() int64 {
 	var cnt int64
 	db.db.Model(&ChatConfig{}).Count(&cnt)
@@ -355,10 +807,11 @@ func (db *DB) GetCustomRoleCount() int64 {
 	return cnt
 }
 
-func (db *DB) LoadAllChatIDs() []int64 {
+func (db *DB) LoadTelegramChatIDs() []int64 {
 	var chatIDs []int64
 
 	tx := db.db.Model(&ChatConfig{}).
+		Where("chat_id < ?", externalChatIDBase).
 		Pluck("chat_id", &chatIDs)
 	if tx.Error != nil {
 		db.log.Warnw(tx.Error.Error())
@@ -366,3 +819,187 @@ func (db *DB) LoadAllChatIDs() []int64 {
 
 	return chatIDs
 }
+
+func (db *DB) LoadExternalChatID(frontend, externalID string) (int64, bool) {
+	chat := ExternalChat{
+		Frontend:   frontend,
+		ExternalID: externalID,
+	}
+
+	err := db.db.
+		Where("frontend = ?", frontend).
+		Where("external_id = ?", externalID).
+		FirstOrCreate(&chat).Error
+	if err != nil {
+		db.log.Warnw(err.Error(), "frontend", frontend)
+		return 0, false
+	}
+
+	return externalChatIDBase + chat.ID, true
+}
+
+func (db *DB) LoadExternalID(chatID int64) (string, bool) {
+	var chat ExternalChat
+
+	err := db.db.
+		Where("id = ?", chatID-externalChatIDBase).
+		Limit(1).
+		Find(&chat).Error
+	if err != nil {
+		db.log.Warnw(err.Error(), "chat_id", chatID)
+	}
+
+	return chat.ExternalID, chat.ExternalID != ""
+}
+
+func (db *DB) AddReminder(reminder *Reminder) bool {
+	err := db.db.Create(reminder).Error
+	if err != nil {
+		db.log.Warnw(err.Error(), "chat_id", reminder.ChatID)
+	}
+
+	return err == nil
+}
+
+func (db *DB) LoadReminders(chatID int64, threadID int) []Reminder {
+	var reminders []Reminder
+
+	err := db.db.
+		Where("chat_id = ?", chatID).
+		Where("thread_id = ?", threadID).
+		Order("due_at asc").
+		Find(&reminders).Error
+	if err != nil {
+		db.log.Warnw(err.Error(), "chat_id", chatID)
+	}
+
+	return reminders
+}
+
+func (db *DB) LoadDueReminders(now time.Time) []Reminder {
+	var reminders []Reminder
+
+	err := db.db.
+		Where("due_at <= ?", now.UTC()).
+		Where("retry_at <= ?", now.UTC()).
+		Order("due_at asc").
+		Find(&reminders).Error
+	if err != nil {
+		db.log.Warnw(err.Error())
+	}
+
+	return reminders
+}
+
+func (db *DB) CountReminders(chatID int64) int64 {
+	var cnt int64
+	db.db.Model(&Reminder{}).Where("chat_id = ?", chatID).Count(&cnt)
+	return cnt
+}
+
+func (db *DB) RemoveReminder(chatID int64, id uint) bool {
+	tx := db.db.
+		Where("chat_id = ?", chatID).
+		Delete(&Reminder{}, id)
+
+	return tx.RowsAffected > 0
+}
+
+func (db *DB) DelayReminder(id uint, attempts int, retryAt time.Time) {
+	err := db.db.Model(&Reminder{}).
+		Where("id = ?", id).
+		Updates(map[string]any{"attempts": attempts, "retry_at": retryAt.UTC()}).Error
+	if err != nil {
+		db.log.Warnw(err.Error(), "reminder_id", id)
+	}
+}
+
+func (db *DB) LoadChatLimit(chatID int64) ChatLimit {
+	limit := ChatLimit{ChatID: chatID}
+
+	err := db.db.Limit(1).Find(&limit, chatID).Error
+	if err != nil {
+		db.log.Warnw(err.Error(), "chat_id", chatID)
+	}
+
+	return limit
+}
+
+func (db *DB) SetChatLimit(chatID int64, column string, value any) bool {
+	limit := ChatLimit{ChatID: chatID}
+
+	err := db.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&limit).Error
+	if err != nil {
+		db.log.Warnw(err.Error(), "chat_id", chatID)
+		return false
+	}
+
+	err = db.db.Model(&limit).Update(column, value).Error
+	if err != nil {
+		db.log.Warnw(err.Error(), "chat_id", chatID)
+	}
+
+	return err == nil
+}
+
+func (db *DB) LoadUsage(subject string) UsageRecord {
+	usage := UsageRecord{Subject: subject}
+
+	err := db.db.
+		Where("subject = ?", subject).
+		Limit(1).
+		Find(&usage).Error
+	if err != nil {
+		db.log.Warnw(err.Error(), "subject", subject)
+	}
+
+	return usage
+}
+
+func (db *DB) SaveUsage(usage *UsageRecord) {
+	err := db.db.Save(usage).Error
+	if err != nil {
+		db.log.Warnw(err.Error(), "subject", usage.Subject)
+	}
+}
+
+func (db *DB) SaveCheckpoint(checkpoint *Checkpoint) bool {
+	err := db.db.Save(checkpoint).Error
+	if err != nil {
+		db.log.Warnw(err.Error(), "chat_id", checkpoint.ChatID, "thread_id", checkpoint.ThreadID)
+	}
+
+	return err == nil
+}
+
+func (db *DB) LoadCheckpoint(chatID int64, threadID int, name string) (Checkpoint, bool) {
+	var checkpoint Checkpoint
+
+	err := db.db.
+		Where("chat_id = ?", chatID).
+		Where("thread_id = ?", threadID).
+		Where("name = ?", name).
+		Limit(1).
+		Find(&checkpoint).Error
+	if err != nil {
+		db.log.Warnw(err.Error(), "chat_id", chatID, "thread_id", threadID)
+	}
+
+	return checkpoint, checkpoint.Name == name && checkpoint.History != ""
+}
+
+func (db *DB) LoadCheckpoints(chatID int64, threadID int) []Checkpoint {
+	var checkpoints []Checkpoint
+
+	err := db.db.
+		Select("chat_id", "thread_id", "name", "created_at", "updated_at").
+		Where("chat_id = ?", chatID).
+		Where("thread_id = ?", threadID).
+		Order("updated_at desc").
+		Find(&checkpoints).Error
+	if err != nil {
+		db.log.Warnw(err.Error(), "chat_id", chatID, "thread_id", threadID)
+	}
+
+	return checkpoints
+}
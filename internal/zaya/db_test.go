@@ -74,6 +74,19 @@ func TestLoadChatConfig(t *testing.T) {
 	require.Equal(t, int64(1), cfg.ChatID)
 }
 
+func TestLoadTelegramChatIDs(t *testing.T) {
+	db := setupTestDB(t)
+
+	external, ok := db.LoadExternalChatID("matrix", "!group:test")
+	require.True(t, ok)
+
+	db.LoadChatConfig(1)
+	db.LoadChatConfig(-100)
+	db.LoadChatConfig(external)
+
+	require.ElementsMatch(t, []int64{1, -100}, db.LoadTelegramChatIDs())
+}
+
 func TestSetFreq(t *testing.T) {
 	db := setupTestDB(t)
 
@@ -84,6 +97,20 @@ func TestSetFreq(t *testing.T) {
 	db.SetFreq(1, 20)
 	cfg = db.LoadChatConfig(1)
 	require.Equal(t, 20, cfg.Freq)
+
+	db.SetFreq(1, 0)
+	cfg = db.LoadChatConfig(1)
+	require.Equal(t, 0, cfg.Freq)
+}
+
+func TestSetLang(t *testing.T) {
+	db := setupTestDB(t)
+
+	db.SetLang(1, "ru")
+	require.Equal(t, "ru", db.LoadChatConfig(1).Lang)
+
+	db.SetLang(1, "")
+	require.Empty(t, db.LoadChatConfig(1).Lang)
 }
 
 func TestSetNickname(t *testing.T) {
@@ -165,6 +192,24 @@ func TestSaveRole(t *testing.T) {
 	require.Empty(t, roles)
 }
 
+func TestRoleLangs(t *testing.T) {
+	db := setupTestDB(t)
+
+	mockTOMLPath := createMockTOMLFile(t)
+	db.UploadGlobalRoles(mockTOMLPath)
+	db.SaveRole(1, "ru", "role_name")
+
+	require.Equal(t, []string{"en", "ru"}, db.LoadRoleLangs(1))
+	require.Equal(t, []string{"en"}, db.LoadRoleLangs(2))
+
+	role, ok := db.FindRoleByPrompt(2, "prompt1")
+	require.True(t, ok)
+	require.Equal(t, "role1", role.Name)
+
+	_, ok = db.FindRoleByPrompt(2, "unknown")
+	require.False(t, ok)
+}
+
 func TestRemoveRole(t *testing.T) {
 	db := setupTestDB(t)
 
@@ -195,3 +240,45 @@ func TestSetRole(t *testing.T) {
 	_, success = db.SetRole(2, roles[0].ID)
 	require.False(t, success)
 }
+
+func TestTopicConfig(t *testing.T) {
+	db := setupTestDB(t)
+
+	db.SetPrompt(1, "chat_prompt")
+
+	cfg, isTopic := db.LoadTopicConfig(1, 0)
+	require.False(t, isTopic)
+	require.Equal(t, "chat_prompt", cfg.Prompt)
+
+	cfg, isTopic = db.LoadTopicConfig(1, 5)
+	require.False(t, isTopic)
+	require.Equal(t, "chat_prompt", cfg.Prompt)
+
+	ok := db.UpdateTopicConfig(1, 5, map[string]any{"prompt": "topic_prompt"})
+	require.False(t, ok)
+
+	db.CreateTopicConfig(1, 5)
+	ok = db.UpdateTopicConfig(1, 5, map[string]any{"prompt": "topic_prompt", "freq": 0})
+	require.True(t, ok)
+
+	db.CreateTopicConfig(1, 5)
+	cfg, isTopic = db.LoadTopicConfig(1, 5)
+	require.True(t, isTopic)
+	require.Equal(t, int64(1), cfg.ChatID)
+	require.Equal(t, "topic_prompt", cfg.Prompt)
+	require.Equal(t, 0, cfg.Freq)
+	require.Equal(t, defaultCfg.Nickname, cfg.Nickname)
+
+	require.Equal(t, "chat_prompt", db.LoadChatConfig(1).Prompt)
+
+	maxHst, ok := db.LoadMaxHistory()
+	require.True(t, ok)
+	require.Equal(t, defaultCfg.MaxHistory, maxHst[ChatKey{ChatID: 1, ThreadID: 5}])
+
+	require.True(t, db.RemoveTopicConfig(1, 5))
+	require.False(t, db.RemoveTopicConfig(1, 5))
+
+	cfg, isTopic = db.LoadTopicConfig(1, 5)
+	require.False(t, isTopic)
+	require.Equal(t, "chat_prompt", cfg.Prompt)
+}
@@ -0,0 +1,321 @@
+package zaya
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDebateTurns = 6
+	maxDebateTurns     = 20
+)
+
+type debateSide struct {
+	role *BotRole
+	chat *aiChat
+}
+
+type debate struct {
+	chat     ChatRef
+	userID   int64
+	topic    string
+	turns    int
+	sides    [2]debateSide
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func (d *debate) cancel() {
+	d.stopOnce.Do(func() {
+		close(d.stop)
+	})
+}
+
+func (d *debate) isStopped() bool {
+	select {
+	case <-d.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (d *debate) wait(dur time.Duration) bool {
+	timer := time.NewTimer(dur)
+	defer timer.Stop()
+
+	select {
+	case <-d.stop:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func cutRoleName(text string, names []string) (string, string, bool) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", "", false
+	}
+
+	if strings.HasPrefix(text, "\"") {
+		end := strings.Index(text[1:], "\"")
+		if end < 1 {
+			return "", "", false
+		}
+
+		return text[1 : end+1], text[end+2:], true
+	}
+
+	longest := ""
+	for _, name := range names {
+		if len(name) <= len(longest) || len(name) > len(text) || !strings.EqualFold(text[:len(name)], name) {
+			continue
+		}
+
+		if len(text) == len(name) || text[len(name)] == ' ' {
+			longest = text[:len(name)]
+		}
+	}
+
+	if longest != "" {
+		return longest, text[len(longest):], true
+	}
+
+	name, rest, _ := strings.Cut(text, " ")
+	return name, rest, true
+}
+
+func splitDebateTurns(text string) (string, int) {
+	fields := strings.Fields(text)
+	if len(fields) > 1 {
+		n, err := strconv.Atoi(fields[len(fields)-1])
+		if err == nil && n >= 1 && n <= maxDebateTurns {
+			return strings.Join(fields[:len(fields)-1], " "), n
+		}
+	}
+
+	return strings.Join(fields, " "), defaultDebateTurns
+}
+
+func parseDebateArgs(arg string, names []string) (string, string, string, int, bool) {
+	nameA, rest, ok := cutRoleName(arg, names)
+	if !ok {
+		return "", "", "", 0, false
+	}
+
+	nameB, rest, ok := cutRoleName(rest, names)
+	if !ok {
+		return "", "", "", 0, false
+	}
+
+	topic, turns := splitDebateTurns(rest)
+	if topic == "" {
+		return "", "", "", 0, false
+	}
+
+	return nameA, nameB, topic, turns, true
+}
+
+func (core *Core) roleList(chatID int64) string {
+	roles := core.db.LoadAllRoleNames(chatID)
+	if len(roles) == 0 {
+		return "There are no roles yet."
+	}
+
+	items := make([]string, 0, len(roles))
+	for _, role := range roles {
+		items = append(items, fmt.Sprintf("%d: %s", role.ID, role.Name))
+	}
+
+	return "Roles: " + strings.Join(items, ", ") + "."
+}
+
+func (core *Core) findRole(chatID int64, arg string) (*BotRole, bool) {
+	id, err := strconv.Atoi(arg)
+	if err == nil {
+		return core.db.LoadRole(chatID, uint(id))
+	}
+
+	for _, role := range core.db.LoadAllRoleNames(chatID) {
+		if strings.EqualFold(role.Name, arg) {
+			return core.db.LoadRole(chatID, role.ID)
+		}
+	}
+
+	return nil, false
+}
+
+func (core *Core) Debate(fe Frontend, msg *Message, arg string) string {
+	chat := msg.Chat
+	usage := fmt.Sprintf(""+
+		"Example usage: debate Socrat \"Mad Hatter\" Is the Moon made of cheese? [turns up to %d]\n"+
+		"Use role names, put a name in quotes if needed, or role ids. %s", maxDebateTurns, core.roleList(chat.ID))
+
+	names := make([]string, 0)
+	for _, role := range core.db.LoadAllRoleNames(chat.ID) {
+		names = append(names, role.Name)
+	}
+
+	nameA, nameB, topic, turns, ok := parseDebateArgs(arg, names)
+	if !ok {
+		return usage
+	}
+
+	roleA, ok := core.findRole(chat.ID, nameA)
+	if !ok {
+		return fmt.Sprintf("There is no role %s. %s", nameA, core.roleList(chat.ID))
+	}
+
+	roleB, ok := core.findRole(chat.ID, nameB)
+	if !ok {
+		return fmt.Sprintf("There is no role %s. %s", nameB, core.roleList(chat.ID))
+	}
+
+	if !core.StartDebate(fe, chat, msg.SenderID, roleA, roleB, topic, turns) {
+		return "A debate is already going on here."
+	}
+
+	return fmt.Sprintf("%s and %s are going to debate for %d turns. Send stop_debate to stop them.",
+		roleA.Name, roleB.Name, turns)
+}
+
+func (core *Core) StartDebate(fe Frontend, chat ChatRef, userID int64, roleA, roleB *BotRole, topic string, turns int) bool {
+	key := chat.Key()
+
+	core.debateLock.Lock()
+	defer core.debateLock.Unlock()
+
+	if _, ok := core.debates[key]; ok {
+		return false
+	}
+
+	d := &debate{
+		chat:   chat,
+		userID: userID,
+		topic:  topic,
+		turns:  turns,
+		stop:   make(chan struct{}),
+	}
+	for i, role := range []*BotRole{roleA, roleB} {
+		d.sides[i] = debateSide{
+			role: role,
+			chat: core.ai.newChat(role.Prompt, role.MaxHistory),
+		}
+	}
+
+	core.debates[key] = d
+	go core.runDebate(fe, d)
+
+	return true
+}
+
+func (core *Core) StopDebate(chat ChatRef) bool {
+	core.debateLock.Lock()
+	defer core.debateLock.Unlock()
+
+	d, ok := core.debates[chat.Key()]
+	if ok {
+		d.cancel()
+	}
+
+	return ok
+}
+
+func (core *Core) IsDebating(chat ChatRef) bool {
+	core.debateLock.Lock()
+	defer core.debateLock.Unlock()
+
+	_, ok := core.debates[chat.Key()]
+	return ok
+}
+
+func (core *Core) debatePause() time.Duration {
+	if core.ai.IsAltModel() {
+		return core.debateDelay * 3
+	}
+
+	return core.debateDelay
+}
+
+func (core *Core) runDebate(fe Frontend, d *debate) {
+	defer func() {
+		core.debateLock.Lock()
+		delete(core.debates, d.chat.Key())
+		core.debateLock.Unlock()
+	}()
+
+	core.log.Infow("debate started",
+		"chat_id", d.chat.ID,
+		"thread_id", d.chat.ThreadID,
+		"role_a", d.sides[0].role.ID,
+		"role_b", d.sides[1].role.ID,
+		"turns", d.turns)
+
+	note := "The debate is over."
+	text := ""
+	for turn := 0; turn < d.turns; turn++ {
+		if turn > 0 && !d.wait(core.debatePause()) {
+			note = "The debate was stopped."
+			break
+		}
+
+		side := d.sides[turn%2]
+		opponent := d.sides[(turn+1)%2].role.Name
+		switch turn {
+		case 0:
+			text = fmt.Sprintf("We are debating: %s\nYour opponent is %s. "+
+				"Make a short opening statement.", d.topic, opponent)
+		case 1:
+			text = fmt.Sprintf("We are debating: %s\nYour opponent is %s. "+
+				"Answer their opening statement shortly:\n\n%s", d.topic, opponent, text)
+		}
+
+		notice, ok := core.checkQuota(d.chat, d.userID, time.Now())
+		if !ok {
+			zayaMetrics.quotaRejections.Inc()
+			note = "The debate was interrupted. " + notice
+			break
+		}
+
+		err := fe.Typing(d.chat)
+		if err != nil {
+			core.log.Warnw(err.Error(), "chat_id", d.chat.ID)
+		}
+
+		key := ChatKey{ChatID: d.chat.ID, ThreadID: d.chat.ThreadID, RoleID: side.role.ID}
+		var reply AIReply
+		core.scheduler.run(d.chat, priorityLow, func() {
+			reply, ok = core.ai.getChatReply(context.Background(), key, side.chat, text, true)
+		})
+		core.chargeQuota(d.chat, d.userID, reply.CtxLen+reply.ReplyLen)
+		if d.isStopped() {
+			note = "The debate was stopped."
+			break
+		}
+		if !ok {
+			note = "The debate was interrupted, I can't get a reply from the model."
+			break
+		}
+
+		core.countReply(reply)
+		_, err = fe.Send(d.chat, nil, reply.Text, SendOptions{Markdown: true, Label: side.role.Name})
+		if err != nil {
+			core.log.Warnw(err.Error(), "chat_id", d.chat.ID)
+			return
+		}
+
+		text = reply.Text
+	}
+
+	_, err := fe.Send(d.chat, nil, note, SendOptions{})
+	if err != nil {
+		core.log.Warnw(err.Error(), "chat_id", d.chat.ID)
+	}
+
+	core.log.Infow("debate finished", "chat_id", d.chat.ID, "thread_id", d.chat.ThreadID)
+}
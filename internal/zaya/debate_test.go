@@ -0,0 +1,99 @@
+package zaya
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestParseDebateArgs(t *testing.T) {
+	names := []string{"Socrat", "Mad", "Mad Hatter"}
+
+	nameA, nameB, topic, turns, ok := parseDebateArgs("Socrat Lunatic Is the Moon made of cheese? 4", names)
+	require.True(t, ok)
+	require.Equal(t, "Socrat", nameA)
+	require.Equal(t, "Lunatic", nameB)
+	require.Equal(t, "Is the Moon made of cheese?", topic)
+	require.Equal(t, 4, turns)
+
+	_, _, topic, turns, ok = parseDebateArgs("1 2 cheese", names)
+	require.True(t, ok)
+	require.Equal(t, "cheese", topic)
+	require.Equal(t, defaultDebateTurns, turns)
+
+	_, _, _, _, ok = parseDebateArgs("1 2", names)
+	require.False(t, ok)
+
+	_, _, topic, turns, ok = parseDebateArgs("1 2 best movie of 1999", names)
+	require.True(t, ok)
+	require.Equal(t, "best movie of 1999", topic)
+	require.Equal(t, defaultDebateTurns, turns)
+
+	nameA, nameB, topic, _, ok = parseDebateArgs("mad hatter socrat tea", names)
+	require.True(t, ok)
+	require.Equal(t, "mad hatter", nameA)
+	require.Equal(t, "socrat", nameB)
+	require.Equal(t, "tea", topic)
+
+	nameA, nameB, topic, _, ok = parseDebateArgs(`"Old Sailor" Socrat the sea 3`, names)
+	require.True(t, ok)
+	require.Equal(t, "Old Sailor", nameA)
+	require.Equal(t, "Socrat", nameB)
+	require.Equal(t, "the sea", topic)
+
+	_, _, _, _, ok = parseDebateArgs(`"Old Sailor Socrat the sea`, names)
+	require.False(t, ok)
+}
+
+func TestDebate(t *testing.T) {
+	core, _ := setupTestCore(t)
+	core.debateDelay = time.Millisecond
+	fe := &testFrontend{}
+	chat := ChatRef{ID: -1}
+	msg := &Message{Chat: chat}
+
+	core.db.SetFreq(chat.ID, 0)
+	core.db.SetPrompt(chat.ID, "socrat prompt")
+	core.db.SaveRole(chat.ID, "en", "Socrat")
+	core.db.SetPrompt(chat.ID, "lunatic prompt")
+	core.db.SaveRole(chat.ID, "en", "Lunatic")
+
+	text, _ := core.RunCommand(fe, msg, "debate", "Socrat Nobody cheese")
+	require.Regexp(t, `^There is no role Nobody\. Roles: \d+: Lunatic, \d+: Socrat\.$`, text)
+
+	text, _ = core.RunCommand(fe, msg, "debate", "socrat lunatic cheese 3")
+	require.Equal(t, "Socrat and Lunatic are going to debate for 3 turns. Send stop_debate to stop them.", text)
+
+	require.Eventually(t, func() bool {
+		return !core.IsDebating(chat)
+	}, time.Second, time.Millisecond)
+
+	sent := fe.sentTexts()
+	require.Len(t, sent, 4)
+	require.Equal(t, "Socrat:\nre: We are debating: cheese\nYour opponent is Lunatic. Make a short opening statement.", sent[0])
+	require.Contains(t, sent[1], "Lunatic:\nre: We are debating: cheese\nYour opponent is Socrat.")
+	require.Equal(t, "Socrat:\nre: "+sent[1][len("Lunatic:\n"):], sent[2])
+	require.Equal(t, "The debate is over.", sent[3])
+
+	_, ok := core.RunCommand(fe, msg, "stop_debate", "")
+	require.True(t, ok)
+	require.False(t, core.StopDebate(chat))
+
+	core.debateDelay = time.Hour
+	fe = &testFrontend{}
+	text, _ = core.RunCommand(fe, msg, "debate", "Socrat Lunatic cheese")
+	require.Contains(t, text, "going to debate")
+
+	text, _ = core.RunCommand(fe, msg, "debate", "Socrat Lunatic cheese")
+	require.Equal(t, "A debate is already going on here.", text)
+
+	require.Eventually(t, func() bool {
+		return len(fe.sentTexts()) == 1
+	}, time.Second, time.Millisecond)
+	require.True(t, core.StopDebate(chat))
+
+	require.Eventually(t, func() bool {
+		return !core.IsDebating(chat)
+	}, time.Second, time.Millisecond)
+	require.Equal(t, "The debate was stopped.", fe.sentTexts()[1])
+}
@@ -0,0 +1,65 @@
+package zaya
+
+import (
+	"context"
+)
+
+type answeredTurn struct {
+	msgID   string
+	index   int
+	persona Persona
+	reply   *Message
+}
+
+func (core *Core) rememberTurn(msg *Message, persona Persona, reply AIReply, sent *Message) {
+	if msg.ID == "" || sent == nil {
+		return
+	}
+
+	core.turns.Set(msg.Chat.Key(), answeredTurn{
+		msgID:   msg.ID,
+		index:   reply.Index,
+		persona: persona,
+		reply:   sent,
+	}, core.personaExp)
+}
+
+func (core *Core) EditMessage(fe Frontend, msg *Message) (bool, error) {
+	turn, ok := core.turns.Get(msg.Chat.Key())
+	if !ok || turn.msgID != msg.ID {
+		return false, nil
+	}
+
+	text := core.userTurn(fe, msg)
+	if text == "" || !core.allowReply(fe, msg, true) {
+		return false, nil
+	}
+
+	err := fe.Typing(msg.Chat)
+	if err != nil {
+		core.log.Warnw(err.Error(), "chat_id", msg.Chat.ID)
+	}
+
+	var reply AIReply
+	ok = false
+	core.scheduler.run(msg.Chat, jobPriority(fe, msg), func() {
+		reply, ok = core.ai.EditReply(context.Background(), turn.persona.chatKey(msg.Chat), turn.index, text)
+	})
+	core.chargeQuota(msg.Chat, msg.SenderID, reply.CtxLen+reply.ReplyLen)
+	if !ok {
+		return false, nil
+	}
+
+	core.log.Infow("reply regenerated", "chat_id", msg.Chat.ID, "thread_id", msg.Chat.ThreadID)
+	core.countReply(reply)
+
+	turn.index = reply.Index
+	core.turns.Set(msg.Chat.Key(), turn, core.personaExp)
+
+	opts := SendOptions{
+		Markdown: true,
+		Continue: !reply.AtEnd,
+		Label:    turn.persona.label(),
+	}
+	return true, fe.Edit(turn.reply, reply.Text, opts)
+}
@@ -0,0 +1,47 @@
+package zaya
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestEditMessage(t *testing.T) {
+	core, llm := setupTestCore(t)
+	fe := &testFrontend{}
+	chat := ChatRef{ID: 1, Private: true}
+
+	_, err := core.ReadMessage(fe, &Message{ID: "10", Chat: chat, SenderID: 1, Text: "helo"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"re: helo"}, fe.sentTexts())
+
+	edited, err := core.EditMessage(fe, &Message{ID: "9", Chat: chat, SenderID: 1, Text: "old"})
+	require.NoError(t, err)
+	require.False(t, edited)
+
+	edited, err = core.EditMessage(fe, &Message{ID: "10", Chat: chat, SenderID: 1, Text: "hello"})
+	require.NoError(t, err)
+	require.True(t, edited)
+	require.Len(t, fe.edited, 1)
+	require.Equal(t, "re: hello", fe.edited[0].Text)
+	require.Len(t, fe.sent, 1)
+
+	messages := core.ai.GetAllMessages()
+	require.Len(t, messages, 3)
+	require.Equal(t, "hello", messages[1].Text)
+	require.Equal(t, "re: hello", messages[2].Text)
+
+	llm.respond = func(_, _ string) string { return "" }
+	edited, err = core.EditMessage(fe, &Message{ID: "10", Chat: chat, SenderID: 1, Text: "hey"})
+	require.NoError(t, err)
+	require.False(t, edited)
+	require.Equal(t, messages, core.ai.GetAllMessages())
+
+	llm.respond = nil
+	_, err = core.ReadMessage(fe, &Message{ID: "11", Chat: chat, SenderID: 1, Text: "next"})
+	require.NoError(t, err)
+
+	edited, err = core.EditMessage(fe, &Message{ID: "10", Chat: chat, SenderID: 1, Text: "hi"})
+	require.NoError(t, err)
+	require.False(t, edited)
+	require.Len(t, fe.edited, 1)
+}
@@ -0,0 +1,124 @@
+package zaya
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/tmc/langchaingo/llms"
+	"strings"
+	"time"
+)
+
+const exportTimeLayout = "2006-01-02 15:04"
+
+type exportMessage struct {
+	Role string    `json:"role"`
+	Name string    `json:"name"`
+	Time time.Time `json:"time"`
+	Text string    `json:"text"`
+}
+
+func parseExportArgs(arg string) (string, bool, bool) {
+	format := "md"
+	withPrompt := false
+	for _, field := range strings.Fields(strings.ToLower(arg)) {
+		switch field {
+		case "md", "markdown":
+			format = "md"
+		case "txt", "text":
+			format = "txt"
+		case "json":
+			format = "json"
+		case "prompt":
+			withPrompt = true
+		default:
+			return "", false, false
+		}
+	}
+
+	return format, withPrompt, true
+}
+
+func exportMessages(history []HistoryMessage, persona Persona, withPrompt bool) []exportMessage {
+	messages := make([]exportMessage, 0, len(history))
+	for _, msg := range history {
+		switch msg.Role {
+		case llms.ChatMessageTypeSystem:
+			if withPrompt {
+				messages = append(messages, exportMessage{Role: "system", Name: "System prompt", Time: msg.Time, Text: msg.Text})
+			}
+		case llms.ChatMessageTypeHuman:
+			messages = append(messages, exportMessage{Role: "user", Name: "User", Time: msg.Time, Text: msg.Text})
+		default:
+			messages = append(messages, exportMessage{Role: "assistant", Name: persona.senderName(), Time: msg.Time, Text: msg.Text})
+		}
+	}
+
+	return messages
+}
+
+func renderExport(messages []exportMessage, format string, loc *time.Location) ([]byte, error) {
+	if format == "json" {
+		return json.MarshalIndent(messages, "", "  ")
+	}
+
+	var text strings.Builder
+	for _, msg := range messages {
+		at := msg.Time.In(loc).Format(exportTimeLayout)
+		if format == "md" {
+			text.WriteString(fmt.Sprintf("### %s\n_%s_\n\n%s\n\n", msg.Name, at, msg.Text))
+		} else {
+			text.WriteString(fmt.Sprintf("[%s] %s:\n%s\n\n", at, msg.Name, msg.Text))
+		}
+	}
+
+	return []byte(text.String()), nil
+}
+
+func isChatAdmin(fe Frontend, msg *Message) bool {
+	if msg.Chat.Private {
+		return true
+	}
+
+	checker, ok := fe.(AdminChecker)
+	return ok && checker.IsChatAdmin(msg.Chat, msg.SenderID)
+}
+
+func (core *Core) Export(fe Frontend, msg *Message, arg string) string {
+	sender, ok := fe.(FileSender)
+	if !ok {
+		return "Export is not supported here."
+	}
+
+	if !isChatAdmin(fe, msg) {
+		return "Only chat admins can export the history."
+	}
+
+	format, withPrompt, ok := parseExportArgs(arg)
+	if !ok {
+		return "" +
+			"Example usage: export md prompt\n" +
+			"The format may be md, txt or json, add prompt to include the system prompt."
+	}
+
+	persona := core.targetPersona(msg)
+	history, ok := core.ai.Transcript(persona.chatKey(msg.Chat))
+	if !ok || len(history) < 2 {
+		return "There is nothing to export yet."
+	}
+
+	loc := core.Location(msg.Chat)
+	data, err := renderExport(exportMessages(history, persona, withPrompt), format, loc)
+	if err != nil {
+		core.log.Warnw(err.Error(), "chat_id", msg.Chat.ID)
+		return "Can't export the history."
+	}
+
+	name := fmt.Sprintf("chat-%s.%s", time.Now().In(loc).Format("2006-01-02-1504"), format)
+	err = sender.SendFile(msg.Chat, msg, name, data)
+	if err != nil {
+		core.log.Warnw(err.Error(), "chat_id", msg.Chat.ID)
+		return "Can't send the exported history."
+	}
+
+	return ""
+}
@@ -0,0 +1,65 @@
+package zaya
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	core, _ := setupTestCore(t)
+	fe := &testFrontend{}
+	chat := ChatRef{ID: 1, Private: true}
+	msg := &Message{Chat: chat, SenderID: 1}
+
+	text, _ := core.RunCommand(fe, msg, "export", "")
+	require.Equal(t, "There is nothing to export yet.", text)
+
+	_, err := core.ReadMessage(fe, &Message{Chat: chat, SenderID: 1, Text: "once upon a time"})
+	require.NoError(t, err)
+
+	text, _ = core.RunCommand(fe, msg, "export", "pdf")
+	require.Contains(t, text, "Example usage")
+
+	text, _ = core.RunCommand(fe, msg, "export", "")
+	require.Empty(t, text)
+	require.Len(t, fe.files, 1)
+	for name, data := range fe.files {
+		require.True(t, strings.HasSuffix(name, ".md"))
+		require.Contains(t, string(data), "### User\n")
+		require.Contains(t, string(data), "### test\n")
+		require.Contains(t, string(data), "re: once upon a time")
+		require.NotContains(t, string(data), defaultCfg.Prompt)
+	}
+
+	fe.files = nil
+	text, _ = core.RunCommand(fe, msg, "export", "json prompt")
+	require.Empty(t, text)
+	for _, data := range fe.files {
+		var messages []exportMessage
+		require.NoError(t, json.Unmarshal(data, &messages))
+		require.Len(t, messages, 3)
+		require.Equal(t, "system", messages[0].Role)
+		require.Equal(t, "user", messages[1].Role)
+		require.Equal(t, "assistant", messages[2].Role)
+		require.False(t, messages[1].Time.IsZero())
+	}
+}
+
+func TestExportAdmins(t *testing.T) {
+	core, _ := setupTestCore(t)
+	fe := &testFrontend{admins: map[int64]bool{7: true}}
+	group := ChatRef{ID: -1}
+
+	_, err := core.ReadMessage(fe, &Message{Chat: group, SenderID: 5, Text: "@test_bot hi"})
+	require.NoError(t, err)
+
+	text, _ := core.RunCommand(fe, &Message{Chat: group, SenderID: 5}, "export", "txt")
+	require.Equal(t, "Only chat admins can export the history.", text)
+	require.Empty(t, fe.files)
+
+	text, _ = core.RunCommand(fe, &Message{Chat: group, SenderID: 7}, "export", "txt")
+	require.Empty(t, text)
+	require.Len(t, fe.files, 1)
+}
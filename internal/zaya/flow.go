@@ -0,0 +1,166 @@
+package zaya
+
+import (
+	"github.com/erni27/imcache"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const flowTTL = 5 * time.Minute
+
+type flowStep struct {
+	question string
+	valid    func(text string) bool
+}
+
+type flow struct {
+	cmd    string
+	values []string
+}
+
+func validNumber(low, high int) func(string) bool {
+	return func(text string) bool {
+		n, err := strconv.Atoi(text)
+		return err == nil && n >= low && n <= high
+	}
+}
+
+func validLanguage(text string) bool {
+	return len(text) == 2 && strings.Trim(strings.ToLower(text), "abcdefghijklmnopqrstuvwxyz") == ""
+}
+
+func validRoleName(text string) bool {
+	return len([]rune(text)) <= 20 && !strings.ContainsAny(text, "\n")
+}
+
+func validAliases(text string) bool {
+	_, ok := parseAliases(text)
+	return ok
+}
+
+func validTimezone(text string) bool {
+	if text == "Local" {
+		return false
+	}
+
+	_, err := time.LoadLocation(text)
+	return err == nil
+}
+
+var commandFlows = map[string][]flowStep{
+	"set_prompt": {
+		{question: "Send me the new system prompt.", valid: func(string) bool { return true }},
+	},
+	"save_role": {
+		{question: "Which language is the role in? Send a two-letter code like en.", valid: validLanguage},
+		{question: "What should the role be called? Up to 20 characters.", valid: validRoleName},
+	},
+	"set_max_history": {
+		{question: "How many messages should I keep in mind? Send 0 to keep as many as the context fits.",
+			valid: validNumber(0, math.MaxInt)},
+	},
+	"set_frequency": {
+		{question: "Which percentage of random group messages should I reply to? Send a number from 0 to 100.",
+			valid: validNumber(0, 100)},
+	},
+	"set_relevance": {
+		{question: "How relevant should a group message be for me to chime in? Send a score from 0 to 100, 0 brings back random replies.",
+			valid: validNumber(0, 100)},
+	},
+	"set_initiative": {
+		{question: "After how many hours of silence should I start a conversation? Send 0 to never speak first.",
+			valid: validNumber(0, 24*7)},
+	},
+	"set_nickname": {
+		{question: "How should I be called? Separate several aliases with commas.", valid: validAliases},
+	},
+	"set_timezone": {
+		{question: "Which timezone is the chat in? Send a name like Europe/Moscow.", valid: validTimezone},
+	},
+}
+
+func flowQuestion(chat ChatRef, step flowStep) string {
+	text := step.question + " Send cancel to stop."
+	if !chat.Private {
+		text += " Reply to this message so I can see your answer."
+	}
+
+	return text
+}
+
+func (core *Core) StartFlow(msg *Message, cmd string) (string, bool) {
+	steps, ok := commandFlows[cmd]
+	if !ok {
+		return "", false
+	}
+
+	key := senderKey{chat: msg.Chat.Key(), sender: msg.SenderID}
+	core.flows.Set(key, flow{cmd: cmd}, imcache.WithExpiration(flowTTL))
+
+	return flowQuestion(msg.Chat, steps[0]), true
+}
+
+func (core *Core) CancelFlow(msg *Message) string {
+	key := senderKey{chat: msg.Chat.Key(), sender: msg.SenderID}
+	_, ok := core.flows.Get(key)
+	if !ok {
+		return "There is nothing to cancel."
+	}
+
+	core.flows.Remove(key)
+	return "Cancelled."
+}
+
+func (core *Core) continueFlow(fe Frontend, msg *Message) (string, bool) {
+	key := senderKey{chat: msg.Chat.Key(), sender: msg.SenderID}
+	current, ok := core.flows.Get(key)
+	if !ok {
+		return "", false
+	}
+
+	text := strings.TrimSpace(msg.Text)
+	if text == "" || strings.HasPrefix(text, "/") {
+		return "", false
+	}
+
+	if strings.EqualFold(text, "cancel") {
+		return core.CancelFlow(msg), true
+	}
+
+	steps := commandFlows[current.cmd]
+	step := steps[len(current.values)]
+	if !step.valid(text) {
+		core.flows.Set(key, current, imcache.WithExpiration(flowTTL))
+		return "That doesn't look right. " + flowQuestion(msg.Chat, step), true
+	}
+
+	current.values = append(current.values, text)
+	if len(current.values) < len(steps) {
+		core.flows.Set(key, current, imcache.WithExpiration(flowTTL))
+		return flowQuestion(msg.Chat, steps[len(current.values)]), true
+	}
+
+	core.flows.Remove(key)
+	core.log.Infow("command flow finished", "chat_id", msg.Chat.ID, "command", current.cmd)
+
+	reply, _ := core.RunCommand(fe, msg, current.cmd, strings.Join(current.values, " "))
+	return reply, true
+}
+
+func (core *Core) readFlow(fe Frontend, msg *Message) bool {
+	text, ok := core.continueFlow(fe, msg)
+	if !ok {
+		return false
+	}
+
+	if text != "" {
+		_, err := fe.Send(msg.Chat, msg, text, SendOptions{})
+		if err != nil {
+			core.log.Warnw(err.Error(), "chat_id", msg.Chat.ID)
+		}
+	}
+
+	return true
+}
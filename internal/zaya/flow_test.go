@@ -0,0 +1,122 @@
+package zaya
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestFlowSaveRole(t *testing.T) {
+	core, _ := setupTestCore(t)
+	fe := &testFrontend{}
+	chat := ChatRef{ID: 1, Private: true}
+	msg := &Message{Chat: chat, SenderID: 1}
+
+	text, ok := core.RunCommand(fe, msg, "save_role", "")
+	require.True(t, ok)
+	require.Contains(t, text, "two-letter code")
+
+	replied, err := core.ReadMessage(fe, &Message{Chat: chat, SenderID: 1, Text: "english"})
+	require.NoError(t, err)
+	require.True(t, replied)
+	require.Contains(t, lastSent(fe), "That doesn't look right.")
+
+	_, err = core.ReadMessage(fe, &Message{Chat: chat, SenderID: 1, Text: "en"})
+	require.NoError(t, err)
+	require.Contains(t, lastSent(fe), "What should the role be called?")
+
+	_, err = core.ReadMessage(fe, &Message{Chat: chat, SenderID: 1, Text: "Pirate Captain"})
+	require.NoError(t, err)
+	require.Equal(t, "Role saved.", lastSent(fe))
+
+	roles := core.db.LoadChatRoleNames(chat.ID)
+	require.Len(t, roles, 1)
+	require.Equal(t, "Pirate Captain", roles[0].Name)
+
+	_, err = core.ReadMessage(fe, &Message{Chat: chat, SenderID: 1, Text: "hello"})
+	require.NoError(t, err)
+	require.Equal(t, "re: hello", lastSent(fe))
+}
+
+func TestFlowCancel(t *testing.T) {
+	core, _ := setupTestCore(t)
+	fe := &testFrontend{}
+	chat := ChatRef{ID: 1, Private: true}
+	msg := &Message{Chat: chat, SenderID: 1}
+
+	text, _ := core.RunCommand(fe, msg, "cancel", "")
+	require.Equal(t, "There is nothing to cancel.", text)
+
+	text, _ = core.RunCommand(fe, msg, "set_max_history", "")
+	require.Contains(t, text, "How many messages")
+
+	_, err := core.ReadMessage(fe, &Message{Chat: chat, SenderID: 1, Text: "Cancel"})
+	require.NoError(t, err)
+	require.Equal(t, "Cancelled.", lastSent(fe))
+
+	text, _ = core.RunCommand(fe, msg, "set_prompt", "")
+	require.Contains(t, text, "new system prompt")
+
+	text, _ = core.RunCommand(fe, msg, "cancel", "")
+	require.Equal(t, "Cancelled.", text)
+	require.Equal(t, defaultCfg.Prompt, core.LoadConfig(chat).Prompt)
+}
+
+func TestFlowGroup(t *testing.T) {
+	core, _ := setupTestCore(t)
+	fe := &testFrontend{}
+	group := ChatRef{ID: -1}
+	core.db.SetFreq(group.ID, 0)
+
+	text, _ := core.RunCommand(fe, &Message{Chat: group, SenderID: 5}, "set_frequency", "")
+	require.Contains(t, text, "Reply to this message")
+
+	replied, err := core.ReadMessage(fe, &Message{Chat: group, SenderID: 6, Text: "42"})
+	require.NoError(t, err)
+	require.False(t, replied)
+	require.Equal(t, 0, core.LoadConfig(group).Freq)
+
+	_, err = core.ReadMessage(fe, &Message{Chat: group, SenderID: 5, Text: "420"})
+	require.NoError(t, err)
+	require.Contains(t, lastSent(fe), "That doesn't look right.")
+
+	_, err = core.ReadMessage(fe, &Message{Chat: group, SenderID: 5, Text: "42"})
+	require.NoError(t, err)
+	require.Equal(t, "Frequency changed.", lastSent(fe))
+	require.Equal(t, 42, core.LoadConfig(group).Freq)
+
+	text, _ = core.RunCommand(fe, &Message{Chat: group, SenderID: 5}, "set_initiative", "")
+	require.Contains(t, text, "hours of silence")
+
+	_, err = core.ReadMessage(fe, &Message{Chat: group, SenderID: 5, Text: "6"})
+	require.NoError(t, err)
+	require.Equal(t, "Initiative changed.", lastSent(fe))
+	require.Equal(t, 6, core.LoadConfig(group).Initiative)
+
+	text, _ = core.RunCommand(fe, &Message{Chat: group, SenderID: 5}, "set_relevance", "")
+	require.Contains(t, text, "from 0 to 100")
+
+	_, err = core.ReadMessage(fe, &Message{Chat: group, SenderID: 5, Text: "60"})
+	require.NoError(t, err)
+	require.Equal(t, "Relevance threshold changed.", lastSent(fe))
+	require.Equal(t, 60, core.LoadConfig(group).Relevance)
+}
+
+func TestFlowSteps(t *testing.T) {
+	require.True(t, validLanguage("en"))
+	require.False(t, validLanguage("e1"))
+	require.True(t, validNumber(0, 100)("100"))
+	require.False(t, validNumber(0, 100)("-1"))
+	require.True(t, validTimezone("Europe/Moscow"))
+	require.False(t, validTimezone("Mars/Olympus"))
+	require.True(t, validAliases("zaya, зая"))
+	require.False(t, validAliases(" , "))
+}
+
+func lastSent(fe *testFrontend) string {
+	texts := fe.sentTexts()
+	if len(texts) == 0 {
+		return ""
+	}
+
+	return texts[len(texts)-1]
+}
@@ -0,0 +1,61 @@
+package zaya
+
+import "errors"
+
+var ErrChatUnavailable = errors.New("chat is unavailable")
+
+type ChatRef struct {
+	ID       int64
+	ThreadID int
+	Private  bool
+}
+
+func (chat ChatRef) Key() ChatKey {
+	return ChatKey{ChatID: chat.ID, ThreadID: chat.ThreadID}
+}
+
+type Message struct {
+	ID          string
+	Chat        ChatRef
+	SenderID    int64
+	SenderName  string
+	Text        string
+	Media       string
+	ForwardFrom string
+	ReplyTo     *Message
+}
+
+type SendOptions struct {
+	Markdown bool
+	Continue bool
+	Label    string
+}
+
+func labelText(text string, opts SendOptions) string {
+	if opts.Label == "" {
+		return text
+	}
+
+	if opts.Markdown {
+		return "**" + opts.Label + "**\n" + text
+	}
+
+	return opts.Label + ":\n" + text
+}
+
+type Frontend interface {
+	Name() string
+	BotID() int64
+	BotMention() string
+	Send(chat ChatRef, replyTo *Message, text string, opts SendOptions) (*Message, error)
+	Edit(msg *Message, text string, opts SendOptions) error
+	Typing(chat ChatRef) error
+}
+
+type FileSender interface {
+	SendFile(chat ChatRef, replyTo *Message, name string, data []byte) error
+}
+
+type AdminChecker interface {
+	IsChatAdmin(chat ChatRef, userID int64) bool
+}
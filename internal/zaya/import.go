@@ -0,0 +1,272 @@
+package zaya
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/erni27/imcache"
+	"strings"
+	"time"
+)
+
+const (
+	maxImportSize     = 1 << 20
+	maxImportMessages = 1000
+	importTTL         = 10 * time.Minute
+)
+
+type pendingImport struct {
+	persona    Persona
+	prompt     string
+	withPrompt bool
+	texts      []string
+}
+
+func markdownHeader(lines []string, i int) (string, time.Time, bool) {
+	name, ok := strings.CutPrefix(lines[i], "### ")
+	if !ok || i+1 >= len(lines) {
+		return "", time.Time{}, false
+	}
+
+	stamp := lines[i+1]
+	if len(stamp) < 3 || !strings.HasPrefix(stamp, "_") || !strings.HasSuffix(stamp, "_") {
+		return "", time.Time{}, false
+	}
+
+	at, err := time.Parse(exportTimeLayout, stamp[1:len(stamp)-1])
+	if err != nil {
+		return "", time.Time{}, false
+	}
+
+	return strings.TrimSpace(name), at, true
+}
+
+func parseMarkdownTranscript(text string) []exportMessage {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	messages := make([]exportMessage, 0)
+	body := make([]string, 0)
+
+	flush := func() {
+		if len(messages) > 0 {
+			messages[len(messages)-1].Text = strings.TrimSpace(strings.Join(body, "\n"))
+		}
+		body = body[:0]
+	}
+
+	for i := 0; i < len(lines); i++ {
+		name, at, ok := markdownHeader(lines, i)
+		if !ok {
+			body = append(body, lines[i])
+			continue
+		}
+
+		flush()
+
+		role := "assistant"
+		switch name {
+		case "System prompt":
+			role = "system"
+		case "User":
+			role = "user"
+		}
+
+		messages = append(messages, exportMessage{Role: role, Name: name, Time: at})
+		i++
+	}
+	flush()
+
+	return messages
+}
+
+func parseTranscript(name string, data []byte) ([]exportMessage, error) {
+	if len(data) > maxImportSize {
+		return nil, errors.New("the file is too large")
+	}
+
+	data = bytes.TrimSpace(data)
+	if strings.HasSuffix(strings.ToLower(name), ".json") || bytes.HasPrefix(data, []byte("[")) {
+		var messages []exportMessage
+		err := json.Unmarshal(data, &messages)
+		if err != nil {
+			return nil, errors.New("the file is not a valid JSON transcript")
+		}
+
+		return messages, nil
+	}
+
+	messages := parseMarkdownTranscript(string(data))
+	if len(messages) == 0 {
+		return nil, errors.New("the file has no messages in the exported Markdown format")
+	}
+
+	return messages, nil
+}
+
+func validateTranscript(messages []exportMessage) (string, []string, error) {
+	if len(messages) > maxImportMessages {
+		return "", nil, fmt.Errorf("the transcript has more than %d messages", maxImportMessages)
+	}
+
+	prompt := ""
+	texts := make([]string, 0, len(messages))
+	for i, msg := range messages {
+		if strings.TrimSpace(msg.Text) == "" {
+			return "", nil, fmt.Errorf("message %d is empty", i+1)
+		}
+
+		expected := "user"
+		if len(texts)%2 == 1 {
+			expected = "assistant"
+		}
+
+		switch {
+		case msg.Role == "system" && i == 0:
+			prompt = msg.Text
+		case msg.Role == expected:
+			texts = append(texts, msg.Text)
+		default:
+			return "", nil, fmt.Errorf("message %d should be from the %s", i+1, expected)
+		}
+	}
+
+	if len(texts)%2 == 1 {
+		texts = texts[:len(texts)-1]
+	}
+
+	if len(texts) == 0 {
+		return "", nil, errors.New("the transcript has no complete exchanges")
+	}
+
+	return prompt, texts, nil
+}
+
+func truncateDialog(texts []string, budget, maxHistory, maxTok int) ([]string, int) {
+	size := 0
+	for i, text := range texts {
+		if i%2 == 0 {
+			size += getMessageLen(text, 4000)
+		} else {
+			size += getMessageLen(text, maxTok)
+		}
+	}
+
+	dropped := 0
+	for dropped < len(texts) &&
+		((budget > 0 && size >= budget) || (maxHistory > 0 && len(texts)-dropped > maxHistory)) {
+		size -= getMessageLen(texts[dropped], 4000) + getMessageLen(texts[dropped+1], maxTok)
+		dropped += 2
+	}
+
+	return texts[dropped:], dropped
+}
+
+func (core *Core) ImportFile(fe Frontend, msg *Message, name string, data []byte, arg string) string {
+	if !isChatAdmin(fe, msg) {
+		return "Only chat admins can import the history."
+	}
+
+	withPrompt := false
+	switch strings.ToLower(strings.TrimSpace(arg)) {
+	case "":
+	case "prompt":
+		withPrompt = true
+	default:
+		return "Example usage: send a transcript with the caption import prompt to replace the system prompt too."
+	}
+
+	messages, err := parseTranscript(name, data)
+	if err != nil {
+		return "Can't import the file: " + err.Error() + "."
+	}
+
+	prompt, texts, err := validateTranscript(messages)
+	if err != nil {
+		return "Can't import the file: " + err.Error() + "."
+	}
+
+	if withPrompt && prompt == "" {
+		return "Can't import the prompt: the transcript has no system prompt, export it with prompt."
+	}
+
+	persona := core.targetPersona(msg)
+	if !withPrompt {
+		prompt = persona.Prompt
+	}
+
+	if !core.promptFits(prompt) {
+		return "Can't import the file: the system prompt leaves too little room for the conversation."
+	}
+
+	budget := 0
+	if core.ai.maxCtx > 0 {
+		budget = core.ai.maxCtx - len(prompt)
+	}
+
+	texts, dropped := truncateDialog(texts, budget, persona.MaxHistory, core.ai.maxTok)
+	if len(texts) == 0 {
+		return "Can't import the file: the conversation doesn't fit the context."
+	}
+
+	core.imports.Set(senderKey{chat: msg.Chat.Key(), sender: msg.SenderID}, pendingImport{
+		persona:    persona,
+		prompt:     prompt,
+		withPrompt: withPrompt,
+		texts:      texts,
+	}, imcache.WithExpiration(importTTL))
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("Ready to import %d messages", len(texts)))
+	if dropped > 0 {
+		text.WriteString(fmt.Sprintf(", %d older ones don't fit and will be dropped", dropped))
+	}
+	text.WriteString(".")
+	if withPrompt {
+		text.WriteString(" The system prompt will be replaced too.")
+	}
+
+	text.WriteString(fmt.Sprintf("\n\nFirst: %s\nLast: %s\n\n",
+		truncateText(texts[0], 100), truncateText(texts[len(texts)-1], 100)))
+	text.WriteString("The current history will be lost. To apply the import, send import confirm, to drop it, send import cancel.")
+
+	return text.String()
+}
+
+func (core *Core) Import(fe Frontend, msg *Message, arg string) string {
+	key := senderKey{chat: msg.Chat.Key(), sender: msg.SenderID}
+
+	switch strings.ToLower(strings.TrimSpace(arg)) {
+	case "confirm":
+	case "cancel":
+		_, ok := core.imports.Get(key)
+		if !ok {
+			return "There is no import to cancel."
+		}
+
+		core.imports.Remove(key)
+		return "Import cancelled."
+	default:
+		return "Send a JSON or Markdown transcript made by export as a document with the caption import, " +
+			"add prompt to the caption to replace the system prompt too."
+	}
+
+	pending, ok := core.imports.Get(key)
+	if !ok {
+		return "There is no import to confirm, send the transcript again."
+	}
+	core.imports.Remove(key)
+
+	if !isChatAdmin(fe, msg) {
+		return "Only chat admins can import the history."
+	}
+
+	if pending.withPrompt && pending.persona.RoleID == 0 {
+		core.SetPrompt(msg.Chat, pending.prompt)
+	}
+
+	texts := append([]string{pending.prompt}, pending.texts...)
+	core.ai.RestoreHistory(pending.persona.chatKey(msg.Chat), texts, pending.persona.MaxHistory)
+	core.turns.Remove(msg.Chat.Key())
+
+	return fmt.Sprintf("Imported %d messages.", len(pending.texts))
+}
@@ -0,0 +1,150 @@
+package zaya
+
+import (
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testTranscript = "" +
+	"### System prompt\n_2030-01-31 10:00_\n\nbe a pirate\n\n" +
+	"### User\n_2030-01-31 10:01_\n\nhello\n\n" +
+	"### test\n_2030-01-31 10:01_\n\nahoy\n\n" +
+	"### User\n_2030-01-31 10:02_\n\nwhere is the treasure?\n\n" +
+	"### test\n_2030-01-31 10:02_\n\nburied\non the island\n\n" +
+	"### User\n_2030-01-31 10:03_\n\nunanswered\n"
+
+func TestParseTranscript(t *testing.T) {
+	messages, err := parseTranscript("chat.md", []byte(testTranscript))
+	require.NoError(t, err)
+	require.Len(t, messages, 6)
+	require.Equal(t, "system", messages[0].Role)
+	require.Equal(t, "assistant", messages[2].Role)
+	require.Equal(t, 1, messages[1].Time.Minute())
+	require.Equal(t, "buried\non the island", messages[4].Text)
+
+	prompt, texts, err := validateTranscript(messages)
+	require.NoError(t, err)
+	require.Equal(t, "be a pirate", prompt)
+	require.Equal(t, []string{"hello", "ahoy", "where is the treasure?", "buried\non the island"}, texts)
+
+	messages, err = parseTranscript("chat.json", []byte(`[{"role": "user", "text": "hi"}, {"role": "assistant", "text": "hey"}]`))
+	require.NoError(t, err)
+	prompt, texts, err = validateTranscript(messages)
+	require.NoError(t, err)
+	require.Empty(t, prompt)
+	require.Equal(t, []string{"hi", "hey"}, texts)
+
+	_, err = parseTranscript("chat.json", []byte(`{"role": "user"}`))
+	require.Error(t, err)
+
+	_, err = parseTranscript("chat.txt", []byte("just some text"))
+	require.Error(t, err)
+
+	_, _, err = validateTranscript([]exportMessage{{Role: "assistant", Text: "hey"}})
+	require.Error(t, err)
+
+	_, _, err = validateTranscript([]exportMessage{{Role: "user", Text: "hi"}, {Role: "system", Text: "prompt"}})
+	require.Error(t, err)
+
+	_, _, err = validateTranscript([]exportMessage{{Role: "user", Text: " "}, {Role: "assistant", Text: "hey"}})
+	require.Error(t, err)
+
+	_, _, err = validateTranscript([]exportMessage{{Role: "user", Text: "hi"}})
+	require.Error(t, err)
+}
+
+func TestMarkdownRoundTrip(t *testing.T) {
+	reply := "Here is the plan.\n\n### Step 1\nBuy a map.\n\n### Step 2\n_carefully_\ndig."
+	history := []exportMessage{
+		{Role: "user", Name: "User", Time: time.Now(), Text: "how do I find the treasure?"},
+		{Role: "assistant", Name: "test", Time: time.Now(), Text: reply},
+	}
+
+	data, err := renderExport(history, "md", time.UTC)
+	require.NoError(t, err)
+
+	messages, err := parseTranscript("chat.md", data)
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	require.Equal(t, "how do I find the treasure?", messages[0].Text)
+	require.Equal(t, "assistant", messages[1].Role)
+	require.Equal(t, reply, messages[1].Text)
+}
+
+func TestTruncateDialog(t *testing.T) {
+	texts := []string{"aaaa", "bbbb", "cccc", "dddd", "eeee", "ffff"}
+
+	kept, dropped := truncateDialog(texts, 0, 0, 100)
+	require.Equal(t, texts, kept)
+	require.Zero(t, dropped)
+
+	kept, dropped = truncateDialog(texts, 20, 0, 100)
+	require.Equal(t, texts[2:], kept)
+	require.Equal(t, 2, dropped)
+
+	kept, dropped = truncateDialog(texts, 0, 2, 100)
+	require.Equal(t, texts[4:], kept)
+	require.Equal(t, 4, dropped)
+
+	kept, _ = truncateDialog(texts, 1, 0, 100)
+	require.Empty(t, kept)
+}
+
+func TestImport(t *testing.T) {
+	core, _ := setupTestCore(t)
+	fe := &testFrontend{}
+	chat := ChatRef{ID: 1, Private: true}
+	msg := &Message{Chat: chat, SenderID: 1}
+
+	_, err := core.ReadMessage(fe, &Message{Chat: chat, SenderID: 1, Text: "old message"})
+	require.NoError(t, err)
+
+	text, _ := core.RunCommand(fe, msg, "import", "confirm")
+	require.Contains(t, text, "no import to confirm")
+
+	text = core.ImportFile(fe, msg, "chat.md", []byte(testTranscript), "")
+	require.Contains(t, text, "Ready to import 4 messages.")
+	require.Contains(t, text, "First: hello")
+	require.NotContains(t, text, "system prompt will be replaced")
+
+	text, _ = core.RunCommand(fe, msg, "import", "cancel")
+	require.Equal(t, "Import cancelled.", text)
+
+	text, _ = core.RunCommand(fe, msg, "import", "confirm")
+	require.Contains(t, text, "no import to confirm")
+
+	text = core.ImportFile(fe, msg, "chat.md", []byte(testTranscript), "prompt")
+	require.Contains(t, text, "system prompt will be replaced")
+
+	text, _ = core.RunCommand(fe, msg, "import", "confirm")
+	require.Equal(t, "Imported 4 messages.", text)
+
+	require.Equal(t, "be a pirate", core.LoadConfig(chat).Prompt)
+	history, ok := core.ai.History(ChatKey{ChatID: chat.ID})
+	require.True(t, ok)
+	require.Equal(t, []string{"be a pirate", "hello", "ahoy", "where is the treasure?", "buried\non the island"}, history)
+
+	longPrompt := strings.Replace(testTranscript, "be a pirate", strings.Repeat("a", 900), 1)
+	text = core.ImportFile(fe, msg, "chat.md", []byte(longPrompt), "prompt")
+	require.Equal(t, "Can't import the file: the system prompt leaves too little room for the conversation.", text)
+}
+
+func TestImportAdmins(t *testing.T) {
+	core, _ := setupTestCore(t)
+	fe := &testFrontend{admins: map[int64]bool{7: true}}
+	group := ChatRef{ID: -1}
+
+	text := core.ImportFile(fe, &Message{Chat: group, SenderID: 5}, "chat.md", []byte(testTranscript), "")
+	require.Equal(t, "Only chat admins can import the history.", text)
+
+	text = core.ImportFile(fe, &Message{Chat: group, SenderID: 7}, "chat.md", []byte(testTranscript), "")
+	require.Contains(t, text, "Ready to import")
+
+	text, _ = core.RunCommand(fe, &Message{Chat: group, SenderID: 5}, "import", "confirm")
+	require.Contains(t, text, "no import to confirm")
+
+	text, _ = core.RunCommand(fe, &Message{Chat: group, SenderID: 7}, "import", "confirm")
+	require.Equal(t, "Imported 4 messages.", text)
+}
@@ -0,0 +1,208 @@
+package zaya
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type chatActivity struct {
+	frontend   string
+	chat       ChatRef
+	lastTime   time.Time
+	day        string
+	sentToday  int
+	unanswered int
+}
+
+func (core *Core) noteActivity(fe Frontend, msg *Message) {
+	if msg.Chat.Private || msg.SenderID == fe.BotID() {
+		return
+	}
+
+	core.activityLock.Lock()
+	defer core.activityLock.Unlock()
+
+	act, ok := core.activity[msg.Chat.Key()]
+	if !ok {
+		act = &chatActivity{}
+		core.activity[msg.Chat.Key()] = act
+	}
+
+	act.frontend = fe.Name()
+	act.chat = msg.Chat
+	act.lastTime = time.Now()
+	act.unanswered = 0
+}
+
+func (core *Core) loadActivity() {
+	core.activityLock.Lock()
+	defer core.activityLock.Unlock()
+
+	for _, saved := range core.db.LoadActivity() {
+		chat := ChatRef{ID: saved.ChatID, ThreadID: saved.ThreadID}
+		core.activity[chat.Key()] = &chatActivity{
+			frontend:   saved.Frontend,
+			chat:       chat,
+			lastTime:   saved.LastTime,
+			day:        saved.Day,
+			sentToday:  saved.SentToday,
+			unanswered: saved.Unanswered,
+		}
+	}
+}
+
+func (core *Core) saveActivity() {
+	core.activityLock.Lock()
+	activity := make([]ChatActivity, 0, len(core.activity))
+	for _, act := range core.activity {
+		activity = append(activity, ChatActivity{
+			ChatID:     act.chat.ID,
+			ThreadID:   act.chat.ThreadID,
+			Frontend:   act.frontend,
+			LastTime:   act.lastTime,
+			Day:        act.day,
+			SentToday:  act.sentToday,
+			Unanswered: act.unanswered,
+		})
+	}
+	core.activityLock.Unlock()
+
+	core.db.SaveActivity(activity)
+}
+
+func (core *Core) runInitiative() {
+	defer core.wg.Done()
+
+	ticker := time.NewTicker(core.initiative.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-core.stop:
+			return
+		case now := <-ticker.C:
+			core.checkInitiative(now)
+		}
+	}
+}
+
+func (core *Core) initiativeDue(act *chatActivity, now time.Time) bool {
+	cfg := core.LoadConfig(act.chat)
+	if cfg.Initiative <= 0 {
+		return false
+	}
+
+	if now.Sub(act.lastTime) < time.Duration(cfg.Initiative)*time.Hour {
+		return false
+	}
+
+	now = now.In(core.Location(act.chat))
+	if !core.initiative.isActiveHour(now.Hour()) {
+		return false
+	}
+
+	day := now.Format(time.DateOnly)
+	if act.day != day {
+		act.day = day
+		act.sentToday = 0
+	}
+
+	return act.sentToday < core.initiative.DailyCap &&
+		act.unanswered < core.initiative.MaxUnanswered
+}
+
+func (core *Core) checkInitiative(now time.Time) {
+	core.activityLock.Lock()
+	due := make([]chatActivity, 0)
+	for _, act := range core.activity {
+		if core.initiativeDue(act, now) {
+			due = append(due, *act)
+		}
+	}
+	core.activityLock.Unlock()
+
+	for _, act := range due {
+		if core.IsDebating(act.chat) {
+			continue
+		}
+
+		fe, ok := core.frontend(act.frontend)
+		if !ok {
+			continue
+		}
+
+		err := core.sendInitiative(fe, act.chat)
+		if err != nil {
+			core.log.Warnw(err.Error(), "chat_id", act.chat.ID, "thread_id", act.chat.ThreadID)
+			continue
+		}
+
+		core.activityLock.Lock()
+		cur, ok := core.activity[act.chat.Key()]
+		if ok && cur.lastTime.Equal(act.lastTime) {
+			cur.lastTime = now
+			cur.sentToday++
+			cur.unanswered++
+
+			if cur.unanswered >= core.initiative.MaxUnanswered {
+				core.log.Infow("initiative paused until somebody answers",
+					"chat_id", act.chat.ID,
+					"thread_id", act.chat.ThreadID)
+			}
+		}
+		core.activityLock.Unlock()
+	}
+}
+
+func (core *Core) sendInitiative(fe Frontend, chat ChatRef) error {
+	notice, ok := core.checkQuota(chat, 0, time.Now())
+	if !ok {
+		zayaMetrics.quotaRejections.Inc()
+		return errors.New(notice)
+	}
+
+	personas := core.loadPersonas(chat, core.LoadConfig(chat))
+	persona := pickPersona(personas)
+
+	key := persona.chatKey(chat)
+	if !core.ai.IsChatStarted(key) {
+		core.ai.StartChat(key, persona.Prompt, persona.MaxHistory)
+	}
+
+	err := fe.Typing(chat)
+	if err != nil {
+		core.log.Warnw(err.Error(), "chat_id", chat.ID)
+	}
+
+	core.log.Infow("taking initiative", "chat_id", chat.ID, "thread_id", chat.ThreadID)
+
+	var reply AIReply
+	core.scheduler.run(chat, priorityLow, func() {
+		reply, ok = core.ai.CompleteChat(context.Background(), key, core.initiative.Prompt)
+	})
+	core.chargeQuota(chat, 0, reply.CtxLen+reply.ReplyLen)
+	if !ok {
+		return errors.New("can't generate a conversation starter")
+	}
+
+	_, err = core.sendReply(fe, chat, nil, persona, reply)
+	if err != nil {
+		return err
+	}
+
+	core.ai.AddReply(key, reply.Text)
+	return nil
+}
+
+func (core *Core) InitiativeInfo(chat ChatRef) string {
+	hours := core.LoadConfig(chat).Initiative
+	if hours <= 0 {
+		return "I never start conversations on my own here."
+	}
+
+	return fmt.Sprintf("I will start a conversation after %d hours of silence, "+
+		"between %d:00 and %d:00, at most %d times a day.",
+		hours, core.initiative.ActiveFrom, core.initiative.ActiveTo, core.initiative.DailyCap)
+}
@@ -0,0 +1,77 @@
+package zaya
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestInitiative(t *testing.T) {
+	core, _ := setupTestCore(t)
+	fe := &testFrontend{}
+	core.AddFrontend(fe)
+	group := ChatRef{ID: -1}
+	core.db.SetFreq(group.ID, 0)
+
+	_, err := core.ReadMessage(fe, &Message{Chat: group, Text: "hi all"})
+	require.NoError(t, err)
+	_, err = core.ReadMessage(fe, &Message{Chat: ChatRef{ID: 1, Private: true}, Text: "hi"})
+	require.NoError(t, err)
+	fe.sent = nil
+
+	noon := time.Now().Add(3 * time.Hour)
+	noon = time.Date(noon.Year(), noon.Month(), noon.Day(), 12, 0, 0, 0, time.Local)
+	core.activity[group.Key()].lastTime = noon.Add(-3 * time.Hour)
+
+	core.checkInitiative(noon)
+	require.Empty(t, fe.sent)
+
+	core.SetInitiative(group, 2)
+	require.Contains(t, core.InitiativeInfo(group), "after 2 hours of silence")
+
+	core.checkInitiative(noon.Add(-12 * time.Hour))
+	require.Empty(t, fe.sent)
+
+	core.checkInitiative(noon)
+	require.Len(t, fe.sent, 1)
+	require.Equal(t, "re: "+core.initiative.Prompt, fe.sent[0].Text)
+
+	history, ok := core.ai.History(group.Key())
+	require.True(t, ok)
+	require.NotContains(t, history, core.initiative.Prompt)
+	require.Equal(t, fe.sent[0].Text, history[len(history)-1])
+
+	core.checkInitiative(noon.Add(time.Hour))
+	require.Len(t, fe.sent, 1)
+
+	core.checkInitiative(noon.Add(2 * time.Hour))
+	require.Len(t, fe.sent, 2)
+
+	core.checkInitiative(noon.Add(5 * time.Hour))
+	require.Len(t, fe.sent, 2)
+
+	_, err = core.ReadMessage(fe, &Message{Chat: group, Text: "sorry, I was away"})
+	require.NoError(t, err)
+	core.activity[group.Key()].lastTime = noon.Add(3 * time.Hour)
+
+	core.checkInitiative(noon.Add(5 * time.Hour))
+	require.Len(t, fe.sent, 2)
+
+	nextDay := noon.Add(24 * time.Hour)
+	core.checkInitiative(nextDay)
+	require.Len(t, fe.sent, 3)
+
+	core.saveActivity()
+	restarted := NewCore(core.ai, core.db, Config{})
+	restarted.loadActivity()
+	act, ok := restarted.activity[group.Key()]
+	require.True(t, ok)
+	require.Equal(t, "test", act.frontend)
+	require.True(t, act.lastTime.Equal(nextDay))
+	require.Equal(t, 1, act.sentToday)
+	require.Equal(t, 1, act.unanswered)
+
+	core.SetInitiative(group, 0)
+	core.checkInitiative(nextDay.Add(5 * time.Hour))
+	require.Len(t, fe.sent, 3)
+}
@@ -0,0 +1,116 @@
+package zaya
+
+import (
+	"fmt"
+	"github.com/erni27/imcache"
+	"strings"
+	"time"
+)
+
+const (
+	minInlineQuery = 3
+	maxInlineQuery = 500
+	inlineCacheTTL = 5 * time.Minute
+)
+
+func (core *Core) inlinePrompt(userID int64, query string) (string, string, string, bool) {
+	if strings.HasPrefix(query, "#") {
+		name, question, _ := strings.Cut(query[1:], " ")
+		role, ok := core.findRole(userID, name)
+		if !ok {
+			return "", "", "", false
+		}
+
+		return role.Prompt, strings.TrimSpace(question), fmt.Sprintf("role:%d", role.ID), true
+	}
+
+	cfg := core.LoadConfig(ChatRef{ID: userID, Private: true})
+	return cfg.Prompt, query, fmt.Sprintf("user:%d", userID), true
+}
+
+func (core *Core) AnswerInline(userID int64, query string) (string, bool) {
+	query = strings.TrimSpace(query)
+	if len(query) > maxInlineQuery {
+		return "", false
+	}
+
+	prompt, question, owner, ok := core.inlinePrompt(userID, query)
+	if !ok || len([]rune(question)) < minInlineQuery {
+		return "", false
+	}
+
+	cacheKey := owner + "\n" + question
+	answer, ok := core.inlineCache.Get(cacheKey)
+	if ok {
+		return answer, true
+	}
+
+	seq := core.beginInline(userID)
+	defer core.endInline(userID, seq)
+
+	time.Sleep(core.inlineDebounce)
+	if !core.isLatestInline(userID, seq) {
+		return "", false
+	}
+
+	if !core.takeInlineSlot(userID) {
+		return "", false
+	}
+
+	chat := ChatRef{ID: userID, Private: true}
+	_, ok = core.checkQuota(chat, userID, time.Now())
+	if !ok {
+		zayaMetrics.quotaRejections.Inc()
+		return "", false
+	}
+
+	var reply AIReply
+	core.scheduler.run(chat, priorityHigh, func() {
+		reply, ok = core.ai.Complete(ChatKey{ChatID: userID}, prompt, question, false)
+	})
+	core.chargeQuota(chat, userID, reply.CtxLen+reply.ReplyLen)
+	if !ok {
+		return "", false
+	}
+
+	core.inlineCache.Set(cacheKey, reply.Text, imcache.WithExpiration(inlineCacheTTL))
+	return reply.Text, core.isLatestInline(userID, seq)
+}
+
+func (core *Core) beginInline(userID int64) uint64 {
+	core.inlineLock.Lock()
+	defer core.inlineLock.Unlock()
+
+	core.inlineSeq++
+	core.inlineLatest[userID] = core.inlineSeq
+	return core.inlineSeq
+}
+
+func (core *Core) isLatestInline(userID int64, seq uint64) bool {
+	core.inlineLock.Lock()
+	defer core.inlineLock.Unlock()
+
+	return core.inlineLatest[userID] == seq
+}
+
+func (core *Core) takeInlineSlot(userID int64) bool {
+	core.inlineLock.Lock()
+	defer core.inlineLock.Unlock()
+
+	_, limited := core.inlineLimits.Get(userID)
+	if limited {
+		return false
+	}
+
+	core.inlineLimits.Set(userID, struct{}{}, imcache.WithExpiration(core.inlineInterval))
+	return true
+}
+
+func (core *Core) endInline(userID int64, seq uint64) {
+	core.inlineLock.Lock()
+	defer core.inlineLock.Unlock()
+
+	if core.inlineLatest[userID] == seq {
+		delete(core.inlineLatest, userID)
+	}
+}
@@ -0,0 +1,71 @@
+package zaya
+
+import (
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+	"testing"
+	"time"
+)
+
+func TestAnswerInline(t *testing.T) {
+	core, llm := setupTestCore(t)
+	core.inlineDebounce = 50 * time.Millisecond
+	core.inlineInterval = time.Millisecond
+
+	user := ChatRef{ID: 1, Private: true}
+	core.SetPrompt(user, "user prompt")
+
+	_, ok := core.AnswerInline(user.ID, "hi")
+	require.False(t, ok)
+
+	answer, ok := core.AnswerInline(user.ID, "what is love?")
+	require.True(t, ok)
+	require.Equal(t, "re: what is love?", answer)
+	require.Equal(t, "user prompt", llm.lastMessages[0].Parts[0].(llms.TextContent).Text)
+
+	llm.lastMessages = nil
+	answer, ok = core.AnswerInline(user.ID, " what is love? ")
+	require.True(t, ok)
+	require.Equal(t, "re: what is love?", answer)
+	require.Nil(t, llm.lastMessages)
+
+	stale := make(chan bool)
+	go func() {
+		_, ok := core.AnswerInline(user.ID, "what is hate?")
+		stale <- ok
+	}()
+	time.Sleep(10 * time.Millisecond)
+	answer, ok = core.AnswerInline(user.ID, "what is hatred?")
+	require.True(t, ok)
+	require.Equal(t, "re: what is hatred?", answer)
+	require.False(t, <-stale)
+	require.Empty(t, core.inlineLatest)
+
+	core.db.SetPrompt(3, "socrat prompt")
+	core.db.SaveRole(3, "en", "Socrat")
+	_, ok = core.AnswerInline(4, "#socrat what is virtue?")
+	require.False(t, ok)
+
+	answer, ok = core.AnswerInline(3, "#socrat what is virtue?")
+	require.True(t, ok)
+	require.Equal(t, "re: what is virtue?", answer)
+	require.Equal(t, "socrat prompt", llm.lastMessages[0].Parts[0].(llms.TextContent).Text)
+}
+
+func TestInlineInterval(t *testing.T) {
+	core, llm := setupTestCore(t)
+	core.inlineDebounce = 0
+
+	answer, ok := core.AnswerInline(1, "what is love?")
+	require.True(t, ok)
+	require.Equal(t, "re: what is love?", answer)
+
+	llm.lastMessages = nil
+	_, ok = core.AnswerInline(1, "what is hate?")
+	require.False(t, ok)
+	require.Nil(t, llm.lastMessages)
+
+	answer, ok = core.AnswerInline(2, "what is hate?")
+	require.True(t, ok)
+	require.Equal(t, "re: what is hate?", answer)
+}
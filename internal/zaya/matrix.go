@@ -0,0 +1,633 @@
+package zaya
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Matrix struct {
+	core   *Core
+	cfg    MatrixConfig
+	client *http.Client
+	log    *zap.SugaredLogger
+
+	userID      string
+	displayName string
+	since       string
+	txnID       atomic.Int64
+
+	roomLock sync.Mutex
+	rooms    map[string]*matrixRoom
+	chats    map[int64]string
+
+	cancel    context.CancelFunc
+	done      chan struct{}
+	isRunning atomic.Bool
+}
+
+type matrixRoom struct {
+	chatID  int64
+	members int
+	queue   []matrixEvent
+	busy    bool
+}
+
+type matrixEvent struct {
+	EventID string          `json:"event_id"`
+	Type    string          `json:"type"`
+	Sender  string          `json:"sender"`
+	Content json.RawMessage `json:"content"`
+}
+
+type matrixMessageContent struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	FormattedBody string `json:"formatted_body,omitempty"`
+	Mentions      *struct {
+		UserIDs []string `json:"user_ids"`
+	} `json:"m.mentions,omitempty"`
+	RelatesTo *struct {
+		InReplyTo *struct {
+			EventID string `json:"event_id"`
+		} `json:"m.in_reply_to,omitempty"`
+		RelType string `json:"rel_type,omitempty"`
+	} `json:"m.relates_to,omitempty"`
+}
+
+type matrixSyncResponse struct {
+	NextBatch string `json:"next_batch"`
+	Rooms     struct {
+		Join map[string]struct {
+			Summary struct {
+				JoinedMembers *int `json:"m.joined_member_count"`
+			} `json:"summary"`
+			Timeline struct {
+				Events []matrixEvent `json:"events"`
+			} `json:"timeline"`
+		} `json:"join"`
+		Invite map[string]json.RawMessage `json:"invite"`
+	} `json:"rooms"`
+}
+
+func NewMatrix(cfg MatrixConfig, core *Core) (*Matrix, bool) {
+	mx := &Matrix{
+		core:   core,
+		cfg:    cfg,
+		client: &http.Client{Timeout: 60 * time.Second},
+		log:    zap.L().Named("matrix").Sugar(),
+		userID: cfg.UserID,
+		rooms:  make(map[string]*matrixRoom),
+		chats:  make(map[int64]string),
+	}
+
+	if cfg.AccessToken == "" {
+		mx.log.Error("matrix access token is required")
+		return nil, false
+	}
+
+	var whoami struct {
+		UserID string `json:"user_id"`
+	}
+	err := mx.request(context.Background(), http.MethodGet, "/account/whoami", nil, &whoami)
+	if err != nil {
+		mx.log.Error(err)
+		return nil, false
+	}
+
+	if mx.userID != "" && mx.userID != whoami.UserID {
+		mx.log.Errorw("access token belongs to another user",
+			"user_id", mx.userID, "token_user_id", whoami.UserID)
+		return nil, false
+	}
+	mx.userID = whoami.UserID
+
+	var profile struct {
+		DisplayName string `json:"displayname"`
+	}
+	path := "/profile/" + url.PathEscape(mx.userID) + "/displayname"
+	err = mx.request(context.Background(), http.MethodGet, path, nil, &profile)
+	if err != nil {
+		mx.log.Warn(err)
+	}
+	mx.displayName = profile.DisplayName
+
+	mx.log.Infow("logged in", "homeserver", cfg.Homeserver, "user_id", mx.userID, "display_name", mx.displayName)
+
+	core.AddFrontend(mx)
+
+	return mx, true
+}
+
+func (mx *Matrix) Name() string {
+	return "matrix"
+}
+
+func (mx *Matrix) BotID() int64 {
+	return matrixUserID(mx.userID)
+}
+
+func (mx *Matrix) BotMention() string {
+	return mx.userID
+}
+
+func matrixUserID(userID string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(userID))
+	return int64(h.Sum64() >> 1)
+}
+
+func (mx *Matrix) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	mx.cancel = cancel
+	mx.done = make(chan struct{})
+
+	go func() {
+		defer close(mx.done)
+
+		mx.log.Info("starting matrix sync")
+		mx.isRunning.Store(true)
+		mx.syncLoop(ctx)
+		mx.isRunning.Store(false)
+		mx.log.Info("matrix sync stopped")
+	}()
+}
+
+func (mx *Matrix) Stop() {
+	if mx.cancel == nil {
+		return
+	}
+
+	mx.cancel()
+	<-mx.done
+}
+
+func (mx *Matrix) IsRunning() bool {
+	return mx.isRunning.Load()
+}
+
+func (mx *Matrix) syncLoop(ctx context.Context) {
+	initial := true
+	for ctx.Err() == nil {
+		query := url.Values{}
+		query.Set("timeout", "30000")
+		if mx.since != "" {
+			query.Set("since", mx.since)
+		}
+
+		var resp matrixSyncResponse
+		err := mx.request(ctx, http.MethodGet, "/sync?"+query.Encode(), nil, &resp)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			mx.log.Warn(err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+
+		mx.since = resp.NextBatch
+
+		for roomID := range resp.Rooms.Invite {
+			mx.joinRoom(ctx, roomID)
+		}
+
+		for roomID, room := range resp.Rooms.Join {
+			if room.Summary.JoinedMembers != nil {
+				mx.setRoomMembers(roomID, *room.Summary.JoinedMembers)
+			}
+
+			if initial {
+				continue
+			}
+
+			mx.enqueueEvents(ctx, roomID, room.Timeline.Events)
+		}
+
+		initial = false
+	}
+}
+
+func (mx *Matrix) joinRoom(ctx context.Context, roomID string) {
+	err := mx.request(ctx, http.MethodPost, "/rooms/"+url.PathEscape(roomID)+"/join", struct{}{}, nil)
+	if err != nil {
+		mx.log.Warnw(err.Error(), "room_id", roomID)
+		return
+	}
+
+	mx.log.Infow("joined room", "room_id", roomID)
+}
+
+func (mx *Matrix) setRoomMembers(roomID string, members int) {
+	mx.roomLock.Lock()
+	defer mx.roomLock.Unlock()
+
+	room, ok := mx.rooms[roomID]
+	if !ok {
+		room = &matrixRoom{}
+		mx.rooms[roomID] = room
+	}
+
+	room.members = members
+}
+
+func (mx *Matrix) enqueueEvents(ctx context.Context, roomID string, events []matrixEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	mx.roomLock.Lock()
+	defer mx.roomLock.Unlock()
+
+	room, ok := mx.rooms[roomID]
+	if !ok {
+		room = &matrixRoom{}
+		mx.rooms[roomID] = room
+	}
+
+	room.queue = append(room.queue, events...)
+	if room.busy {
+		return
+	}
+
+	room.busy = true
+	go mx.drainRoom(ctx, roomID, room)
+}
+
+func (mx *Matrix) drainRoom(ctx context.Context, roomID string, room *matrixRoom) {
+	for {
+		mx.roomLock.Lock()
+		if len(room.queue) == 0 || ctx.Err() != nil {
+			room.queue = nil
+			room.busy = false
+			mx.roomLock.Unlock()
+			return
+		}
+
+		event := room.queue[0]
+		room.queue = room.queue[1:]
+		mx.roomLock.Unlock()
+
+		mx.handleEvent(ctx, roomID, event)
+	}
+}
+
+func (mx *Matrix) loadChat(roomID string) (ChatRef, bool) {
+	mx.roomLock.Lock()
+	defer mx.roomLock.Unlock()
+
+	room, ok := mx.rooms[roomID]
+	if !ok {
+		room = &matrixRoom{}
+		mx.rooms[roomID] = room
+	}
+
+	if room.chatID == 0 {
+		chatID, ok := mx.core.db.LoadExternalChatID(mx.Name(), roomID)
+		if !ok {
+			return ChatRef{}, false
+		}
+
+		room.chatID = chatID
+		mx.chats[chatID] = roomID
+	}
+
+	return ChatRef{
+		ID:      room.chatID,
+		Private: room.members > 0 && room.members <= 2,
+	}, true
+}
+
+func (mx *Matrix) loadRoomID(chatID int64) (string, bool) {
+	mx.roomLock.Lock()
+	roomID, ok := mx.chats[chatID]
+	mx.roomLock.Unlock()
+
+	if ok {
+		return roomID, true
+	}
+
+	return mx.core.db.LoadExternalID(chatID)
+}
+
+func (mx *Matrix) newMessage(chat ChatRef, event matrixEvent) (*Message, bool) {
+	if event.Type != "m.room.message" {
+		return nil, false
+	}
+
+	var content matrixMessageContent
+	err := json.Unmarshal(event.Content, &content)
+	if err != nil || content.MsgType != "m.text" {
+		return nil, false
+	}
+
+	if content.RelatesTo != nil && content.RelatesTo.RelType == "m.replace" {
+		return nil, false
+	}
+
+	msg := &Message{
+		ID:         event.EventID,
+		Chat:       chat,
+		SenderID:   matrixUserID(event.Sender),
+		SenderName: event.Sender,
+		Text:       stripMatrixReplyFallback(content.Body),
+	}
+
+	return msg, true
+}
+
+func stripMatrixReplyFallback(body string) string {
+	if !strings.HasPrefix(body, "> ") {
+		return body
+	}
+
+	lines := strings.Split(body, "\n")
+	idx := 0
+	for idx < len(lines) && strings.HasPrefix(lines[idx], ">") {
+		idx++
+	}
+
+	return strings.TrimSpace(strings.Join(lines[idx:], "\n"))
+}
+
+func (mx *Matrix) isMentioned(content matrixMessageContent) bool {
+	if content.Mentions != nil {
+		for _, userID := range content.Mentions.UserIDs {
+			if userID == mx.userID {
+				return true
+			}
+		}
+	}
+
+	return strings.Contains(content.FormattedBody, "matrix.to/#/"+mx.userID) ||
+		strings.Contains(content.FormattedBody, "matrix.to/#/"+url.PathEscape(mx.userID))
+}
+
+func (mx *Matrix) mentionText(content matrixMessageContent, text string) string {
+	if strings.Contains(text, mx.userID) || !mx.isMentioned(content) {
+		return text
+	}
+
+	if mx.displayName != "" && strings.Contains(text, mx.displayName) {
+		if strings.Contains(text, mx.displayName+":") {
+			return strings.Replace(text, mx.displayName+":", mx.userID, 1)
+		}
+
+		return strings.Replace(text, mx.displayName, mx.userID, 1)
+	}
+
+	return mx.userID + " " + text
+}
+
+func (mx *Matrix) loadEvent(ctx context.Context, roomID, eventID string) (matrixEvent, bool) {
+	var event matrixEvent
+	path := "/rooms/" + url.PathEscape(roomID) + "/event/" + url.PathEscape(eventID)
+	err := mx.request(ctx, http.MethodGet, path, nil, &event)
+	if err != nil {
+		mx.log.Warnw(err.Error(), "room_id", roomID)
+		return event, false
+	}
+
+	return event, true
+}
+
+func (mx *Matrix) handleEvent(ctx context.Context, roomID string, event matrixEvent) {
+	if event.Sender == mx.userID {
+		return
+	}
+
+	chat, ok := mx.loadChat(roomID)
+	if !ok {
+		return
+	}
+
+	msg, ok := mx.newMessage(chat, event)
+	if !ok {
+		return
+	}
+
+	var content matrixMessageContent
+	_ = json.Unmarshal(event.Content, &content)
+	if content.RelatesTo != nil && content.RelatesTo.InReplyTo != nil {
+		replyEvent, ok := mx.loadEvent(ctx, roomID, content.RelatesTo.InReplyTo.EventID)
+		if ok {
+			msg.ReplyTo, _ = mx.newMessage(chat, replyEvent)
+		}
+	}
+
+	if msg.ReplyTo == nil || msg.ReplyTo.SenderID != mx.BotID() {
+		msg.Text = mx.mentionText(content, msg.Text)
+	}
+
+	beginTime := time.Now().UnixNano()
+
+	var err error
+	var replied bool
+	if strings.HasPrefix(msg.Text, "!") {
+		err = mx.runCommand(msg)
+		replied = true
+	} else {
+		replied, err = mx.core.ReadMessage(mx, msg)
+	}
+
+	if replied {
+		duration := float64(time.Now().UnixNano()-beginTime) / 1000000
+		mx.log.Infow("user message",
+			"chat_id", chat.ID,
+			"room_id", roomID,
+			"user_name", event.Sender,
+			"size", len(msg.Text),
+			"dur", fmt.Sprintf("%.2f", duration),
+			"err", err)
+	}
+}
+
+func (mx *Matrix) runCommand(msg *Message) error {
+	cmd, arg, _ := strings.Cut(msg.Text[1:], " ")
+	arg = strings.TrimSpace(arg)
+
+	var reply string
+	if cmd == "help" {
+		reply = mx.core.CommandHelp("!")
+	} else {
+		var ok bool
+		reply, ok = mx.core.RunCommand(mx, msg, cmd, arg)
+		if !ok {
+			reply = "Unknown command, send !help."
+		}
+	}
+
+	if reply == "" {
+		return nil
+	}
+
+	_, err := mx.Send(msg.Chat, msg, reply, SendOptions{})
+	return err
+}
+
+func (mx *Matrix) nextTxnID() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36) + "." + strconv.FormatInt(mx.txnID.Add(1), 10)
+}
+
+func (mx *Matrix) sendEvent(chat ChatRef, content map[string]any) (string, error) {
+	roomID, ok := mx.loadRoomID(chat.ID)
+	if !ok {
+		return "", fmt.Errorf("unknown matrix chat: %d", chat.ID)
+	}
+
+	path := "/rooms/" + url.PathEscape(roomID) + "/send/m.room.message/" + mx.nextTxnID()
+
+	var resp struct {
+		EventID string `json:"event_id"`
+	}
+	err := mx.request(context.Background(), http.MethodPut, path, content, &resp)
+	return resp.EventID, err
+}
+
+func (mx *Matrix) Send(chat ChatRef, replyTo *Message, text string, opts SendOptions) (*Message, error) {
+	opts.Markdown = false
+	text = labelText(text, opts)
+	if opts.Continue {
+		text += "\n\n(reply \"continue\" to get the rest)"
+	}
+
+	content := map[string]any{
+		"msgtype": "m.text",
+		"body":    text,
+	}
+	if replyTo != nil {
+		content["m.relates_to"] = map[string]any{
+			"m.in_reply_to": map[string]any{
+				"event_id": replyTo.ID,
+			},
+		}
+	}
+
+	eventID, err := mx.sendEvent(chat, content)
+	if err != nil {
+		zayaMetrics.sendErrors.WithLabelValues("matrix_send").Inc()
+		if strings.Contains(err.Error(), "M_FORBIDDEN") {
+			err = fmt.Errorf("%w: %w", ErrChatUnavailable, err)
+		}
+		return nil, err
+	}
+
+	return &Message{
+		ID:         eventID,
+		Chat:       chat,
+		SenderID:   mx.BotID(),
+		SenderName: mx.userID,
+		Text:       text,
+	}, nil
+}
+
+func (mx *Matrix) Edit(msg *Message, text string, opts SendOptions) error {
+	opts.Markdown = false
+	text = labelText(text, opts)
+	if opts.Continue {
+		text += "\n\n(reply \"continue\" to get the rest)"
+	}
+
+	content := map[string]any{
+		"msgtype": "m.text",
+		"body":    "* " + text,
+		"m.new_content": map[string]any{
+			"msgtype": "m.text",
+			"body":    text,
+		},
+		"m.relates_to": map[string]any{
+			"rel_type": "m.replace",
+			"event_id": msg.ID,
+		},
+	}
+
+	_, err := mx.sendEvent(msg.Chat, content)
+	if err != nil {
+		zayaMetrics.sendErrors.WithLabelValues("matrix_edit").Inc()
+	}
+
+	return err
+}
+
+func (mx *Matrix) Typing(chat ChatRef) error {
+	roomID, ok := mx.loadRoomID(chat.ID)
+	if !ok {
+		return fmt.Errorf("unknown matrix chat: %d", chat.ID)
+	}
+
+	path := "/rooms/" + url.PathEscape(roomID) + "/typing/" + url.PathEscape(mx.userID)
+	body := map[string]any{
+		"typing":  true,
+		"timeout": 5000,
+	}
+
+	err := mx.request(context.Background(), http.MethodPut, path, body, nil)
+	if err != nil {
+		zayaMetrics.sendErrors.WithLabelValues("matrix_typing").Inc()
+	}
+
+	return err
+}
+
+func (mx *Matrix) request(ctx context.Context, method, path string, body, result any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	endpoint := strings.TrimSuffix(mx.cfg.Homeserver, "/") + "/_matrix/client/v3" + path
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+mx.cfg.AccessToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := mx.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		var matrixErr struct {
+			ErrCode string `json:"errcode"`
+			Error   string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&matrixErr)
+		if matrixErr.ErrCode == "" {
+			return errors.New(resp.Status)
+		}
+
+		return fmt.Errorf("%s: %s: %s", resp.Status, matrixErr.ErrCode, matrixErr.Error)
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
@@ -0,0 +1,198 @@
+package zaya
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type stubHomeserver struct {
+	t      *testing.T
+	lock   sync.Mutex
+	syncs  int
+	joined []string
+	sent   map[string][]map[string]any
+	events map[string]matrixEvent
+}
+
+func newStubHomeserver(t *testing.T) (*httptest.Server, *stubHomeserver) {
+	hs := &stubHomeserver{
+		t:    t,
+		sent: make(map[string][]map[string]any),
+		events: map[string]matrixEvent{
+			"$bot": {
+				EventID: "$bot",
+				Type:    "m.room.message",
+				Sender:  "@zaya:test",
+				Content: json.RawMessage(`{"msgtype":"m.text","body":"earlier reply"}`),
+			},
+		},
+	}
+
+	srv := httptest.NewServer(hs)
+	t.Cleanup(srv.Close)
+
+	return srv, hs
+}
+
+func (hs *stubHomeserver) sentTo(roomID string) []map[string]any {
+	hs.lock.Lock()
+	defer hs.lock.Unlock()
+
+	return append([]map[string]any(nil), hs.sent[roomID]...)
+}
+
+func (hs *stubHomeserver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"errcode":"M_UNKNOWN_TOKEN","error":"bad token"}`))
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/_matrix/client/v3")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	hs.lock.Lock()
+	defer hs.lock.Unlock()
+
+	switch {
+	case path == "/account/whoami":
+		_, _ = w.Write([]byte(`{"user_id":"@zaya:test"}`))
+	case path == "/profile/@zaya:test/displayname":
+		_, _ = w.Write([]byte(`{"displayname":"Zaya"}`))
+	case path == "/sync":
+		hs.syncs++
+		switch hs.syncs {
+		case 1:
+			_, _ = w.Write([]byte(`{"next_batch":"s1","rooms":{
+				"join":{"!dm:test":{"summary":{"m.joined_member_count":2},
+					"timeline":{"events":[{"event_id":"$old","type":"m.room.message","sender":"@user:test",
+						"content":{"msgtype":"m.text","body":"old message"}}]}},
+					"!group:test":{"summary":{"m.joined_member_count":5},"timeline":{"events":[]}}},
+				"invite":{"!inv:test":{}}}}`))
+		case 2:
+			_, _ = w.Write([]byte(`{"next_batch":"s2","rooms":{"join":{
+				"!dm:test":{"timeline":{"events":[{"event_id":"$1","type":"m.room.message","sender":"@user:test",
+					"content":{"msgtype":"m.text","body":"hello"}}]}},
+				"!group:test":{"timeline":{"events":[
+					{"event_id":"$2","type":"m.room.message","sender":"@user:test",
+						"content":{"msgtype":"m.text","body":"nothing to see"}},
+					{"event_id":"$3","type":"m.room.message","sender":"@user:test",
+						"content":{"msgtype":"m.text","body":"> <@zaya:test> earlier reply\n\nwhy?",
+							"m.relates_to":{"m.in_reply_to":{"event_id":"$bot"}}}},
+					{"event_id":"$4","type":"m.room.message","sender":"@user:test",
+						"content":{"msgtype":"m.text","body":"!get_nickname"}},
+					{"event_id":"$5","type":"m.room.message","sender":"@user:test",
+						"content":{"msgtype":"m.text","body":"Zaya: hi there",
+							"formatted_body":"<a href=\"https://matrix.to/#/@zaya:test\">Zaya</a>: hi there",
+							"m.mentions":{"user_ids":["@zaya:test"]}}}]}}}}}`))
+		default:
+			hs.lock.Unlock()
+			select {
+			case <-r.Context().Done():
+			case <-time.After(50 * time.Millisecond):
+			}
+			hs.lock.Lock()
+			_, _ = w.Write([]byte(`{"next_batch":"s2"}`))
+		}
+	case len(parts) == 3 && parts[0] == "rooms" && parts[2] == "join":
+		hs.joined = append(hs.joined, parts[1])
+		_, _ = w.Write([]byte(`{}`))
+	case len(parts) == 5 && parts[0] == "rooms" && parts[2] == "send":
+		var content map[string]any
+		require.NoError(hs.t, json.NewDecoder(r.Body).Decode(&content))
+		hs.sent[parts[1]] = append(hs.sent[parts[1]], content)
+		_, _ = w.Write([]byte(`{"event_id":"$sent"}`))
+	case len(parts) == 4 && parts[0] == "rooms" && parts[2] == "typing":
+		_, _ = w.Write([]byte(`{}`))
+	case len(parts) == 4 && parts[0] == "rooms" && parts[2] == "event":
+		event, ok := hs.events[parts[3]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(event)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestNewMatrix(t *testing.T) {
+	core, _ := setupTestCore(t)
+	srv, _ := newStubHomeserver(t)
+
+	_, ok := NewMatrix(MatrixConfig{Homeserver: srv.URL, AccessToken: "wrong"}, core)
+	require.False(t, ok)
+
+	_, ok = NewMatrix(MatrixConfig{Homeserver: srv.URL, UserID: "@other:test", AccessToken: "token"}, core)
+	require.False(t, ok)
+
+	mx, ok := NewMatrix(MatrixConfig{Homeserver: srv.URL, AccessToken: "token"}, core)
+	require.True(t, ok)
+	require.Equal(t, "@zaya:test", mx.BotMention())
+	require.Equal(t, "Zaya", mx.displayName)
+}
+
+func TestMatrixSync(t *testing.T) {
+	core, _ := setupTestCore(t)
+	srv, hs := newStubHomeserver(t)
+
+	groupID, ok := core.db.LoadExternalChatID("matrix", "!group:test")
+	require.True(t, ok)
+	core.db.SetFreq(groupID, 0)
+
+	mx, ok := NewMatrix(MatrixConfig{Homeserver: srv.URL, AccessToken: "token"}, core)
+	require.True(t, ok)
+
+	mx.Start()
+	require.Eventually(t, mx.IsRunning, time.Second, 10*time.Millisecond)
+
+	require.Eventually(t, func() bool {
+		return len(hs.sentTo("!dm:test")) == 1 && len(hs.sentTo("!group:test")) == 3
+	}, 2*time.Second, 10*time.Millisecond)
+
+	mx.Stop()
+	require.False(t, mx.IsRunning())
+
+	dm := hs.sentTo("!dm:test")
+	require.Equal(t, "re: hello", dm[0]["body"])
+
+	replies := make([]string, 0, 3)
+	for _, content := range hs.sentTo("!group:test") {
+		replies = append(replies, content["body"].(string))
+	}
+	require.Equal(t, []string{"re: why?", "You can call me test.", "re: hi there"}, replies)
+
+	hs.lock.Lock()
+	require.Equal(t, []string{"!inv:test"}, hs.joined)
+	hs.lock.Unlock()
+
+	dmID, ok := core.db.LoadExternalChatID("matrix", "!dm:test")
+	require.True(t, ok)
+	require.NotEqual(t, dmID, groupID)
+	require.Greater(t, dmID, int64(1)<<53)
+}
+
+func TestMatrixMentionText(t *testing.T) {
+	mx := &Matrix{userID: "@zaya:test", displayName: "Zaya"}
+
+	var content matrixMessageContent
+	require.Equal(t, "Zaya: hi", mx.mentionText(content, "Zaya: hi"))
+
+	require.NoError(t, json.Unmarshal([]byte(`{"body":"Zaya: hi","m.mentions":{"user_ids":["@zaya:test"]}}`), &content))
+	require.Equal(t, "@zaya:test hi", mx.mentionText(content, "Zaya: hi"))
+	require.Equal(t, "@zaya:test what's up", mx.mentionText(content, "what's up"))
+
+	content = matrixMessageContent{FormattedBody: `ask <a href="https://matrix.to/#/@zaya:test">Zaya</a> now`}
+	require.Equal(t, "ask @zaya:test now", mx.mentionText(content, "ask Zaya now"))
+}
+
+func TestStripMatrixReplyFallback(t *testing.T) {
+	require.Equal(t, "hello", stripMatrixReplyFallback("hello"))
+	require.Equal(t, "why?", stripMatrixReplyFallback("> <@zaya:test> earlier reply\n> more\n\nwhy?"))
+}
@@ -0,0 +1,139 @@
+package zaya
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+type metrics struct {
+	registry *prometheus.Registry
+
+	generations     *prometheus.CounterVec
+	genDuration     prometheus.Histogram
+	genRetries      *prometheus.CounterVec
+	modelSwitches   *prometheus.CounterVec
+	outputMessages  prometheus.Counter
+	outputLength    prometheus.Histogram
+	inputContext    prometheus.Histogram
+	sendErrors      *prometheus.CounterVec
+	quotaRejections prometheus.Counter
+	queueLength     prometheus.Gauge
+	mergedMessages  prometheus.Counter
+	relevanceChecks *prometheus.CounterVec
+	chatCacheSize   prometheus.GaugeFunc
+	chatCacheSizeFn func() float64
+}
+
+var zayaMetrics = newMetrics()
+
+func newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+	}
+
+	m.generations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "zaya",
+		Name:      "generations_total",
+		Help:      "Count of AI generations by result.",
+	}, []string{"result"})
+
+	m.genDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "zaya",
+		Name:      "generation_duration_seconds",
+		Help:      "Duration of AI replies including retries.",
+		Buckets:   []float64{0.25, 0.5, 1, 2, 4, 8, 15, 30, 60, 120},
+	})
+
+	m.genRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "zaya",
+		Name:      "generation_retries_total",
+		Help:      "Count of repeated generation attempts by reason.",
+	}, []string{"reason"})
+
+	m.modelSwitches = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "zaya",
+		Name:      "model_switches_total",
+		Help:      "Count of switches between the primary and the auxiliary models.",
+	}, []string{"model"})
+
+	m.outputMessages = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "zaya",
+		Name:      "output_messages_total",
+		Help:      "Count of AI messages sent to users.",
+	})
+
+	m.outputLength = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "zaya",
+		Name:      "output_message_length_bytes",
+		Help:      "Length of AI messages sent to users.",
+		Buckets:   prometheus.ExponentialBuckets(64, 2, 8),
+	})
+
+	m.inputContext = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "zaya",
+		Name:      "input_context_length_bytes",
+		Help:      "Length of chat history passed to the model.",
+		Buckets:   prometheus.ExponentialBuckets(256, 2, 8),
+	})
+
+	m.sendErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "zaya",
+		Name:      "telegram_send_errors_total",
+		Help:      "Count of failed Telegram API calls by operation.",
+	}, []string{"op"})
+
+	m.quotaRejections = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "zaya",
+		Name:      "quota_rejections_total",
+		Help:      "Count of messages left unanswered because of rate limits or quotas.",
+	})
+
+	m.queueLength = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "zaya",
+		Name:      "generation_queue_length",
+		Help:      "Count of generations waiting for a free worker.",
+	})
+
+	m.mergedMessages = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "zaya",
+		Name:      "merged_messages_total",
+		Help:      "Count of messages merged into a pending turn of the same user.",
+	})
+
+	m.relevanceChecks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "zaya",
+		Name:      "relevance_checks_total",
+		Help:      "Count of unsolicited reply decisions by result.",
+	}, []string{"result"})
+
+	m.chatCacheSize = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "zaya",
+		Name:      "chat_cache_size",
+		Help:      "Count of chats kept in memory.",
+	}, func() float64 {
+		if m.chatCacheSizeFn == nil {
+			return 0
+		}
+		return m.chatCacheSizeFn()
+	})
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.generations,
+		m.genDuration,
+		m.genRetries,
+		m.modelSwitches,
+		m.outputMessages,
+		m.outputLength,
+		m.inputContext,
+		m.sendErrors,
+		m.quotaRejections,
+		m.queueLength,
+		m.mergedMessages,
+		m.relevanceChecks,
+		m.chatCacheSize,
+	)
+
+	return m
+}
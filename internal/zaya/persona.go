@@ -0,0 +1,222 @@
+package zaya
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+func (persona Persona) chatKey(chat ChatRef) ChatKey {
+	key := chat.Key()
+	key.RoleID = persona.RoleID
+	return key
+}
+
+func (persona Persona) label() string {
+	if persona.RoleID == 0 {
+		return ""
+	}
+
+	return persona.Name
+}
+
+func (persona Persona) senderName() string {
+	if persona.Name != "" {
+		return persona.Name
+	}
+
+	if persona.Nickname != "" {
+		return persona.Nickname
+	}
+
+	return "bot"
+}
+
+func (persona Persona) aliases() []string {
+	aliases := splitAliases(persona.Aliases)
+	if persona.Nickname != "" {
+		aliases = append([]string{persona.Nickname}, aliases...)
+	}
+
+	return aliases
+}
+
+func configPersona(cfg *ChatConfig) Persona {
+	return Persona{
+		Nickname:   cfg.Nickname,
+		Aliases:    cfg.Aliases,
+		Prompt:     cfg.Prompt,
+		MaxHistory: cfg.MaxHistory,
+		Weight:     1,
+	}
+}
+
+func (core *Core) loadPersonas(chat ChatRef, cfg *ChatConfig) []Persona {
+	personas := core.db.LoadPersonas(chat.ID, chat.ThreadID)
+	if len(personas) == 0 {
+		return []Persona{configPersona(cfg)}
+	}
+
+	return personas
+}
+
+func totalWeight(personas []Persona) int {
+	total := 0
+	for _, persona := range personas {
+		total += max(persona.Weight, 0)
+	}
+
+	return total
+}
+
+func pickPersona(personas []Persona) Persona {
+	total := totalWeight(personas)
+	if total == 0 {
+		return personas[rand.Intn(len(personas))]
+	}
+
+	n := rand.Intn(total)
+	for _, persona := range personas {
+		n -= max(persona.Weight, 0)
+		if n < 0 {
+			return persona
+		}
+	}
+
+	return personas[len(personas)-1]
+}
+
+func findPersona(personas []Persona, text string) (Persona, bool) {
+	persona, _, _, ok := matchPersona(personas, text)
+	return persona, ok
+}
+
+func personaMessageKey(msg *Message) string {
+	return fmt.Sprintf("%d/%s", msg.Chat.ID, msg.ID)
+}
+
+func (core *Core) rememberPersona(msg *Message, persona Persona) {
+	if msg == nil || persona.RoleID == 0 {
+		return
+	}
+
+	core.personaMsgs.Set(personaMessageKey(msg), persona.RoleID, core.personaExp)
+}
+
+func (core *Core) repliedPersona(msg *Message, personas []Persona) (Persona, bool) {
+	if msg == nil {
+		return Persona{}, false
+	}
+
+	roleID, ok := core.personaMsgs.Get(personaMessageKey(msg))
+	if !ok {
+		return Persona{}, false
+	}
+
+	for _, persona := range personas {
+		if persona.RoleID == roleID {
+			return persona, true
+		}
+	}
+
+	return Persona{}, false
+}
+
+func (core *Core) lastPersona(chat ChatRef, personas []Persona) (Persona, bool) {
+	turn, ok := core.turns.Get(chat.Key())
+	if !ok {
+		return Persona{}, false
+	}
+
+	for _, persona := range personas {
+		if persona.RoleID == turn.persona.RoleID {
+			return persona, true
+		}
+	}
+
+	return Persona{}, false
+}
+
+func (core *Core) AddPersona(chat ChatRef, roleID uint, weight int) (*BotRole, bool) {
+	role, ok := core.db.LoadRole(chat.ID, roleID)
+	if !ok || !core.promptFits(role.Prompt) {
+		return nil, false
+	}
+
+	role, ok = core.db.AddPersona(chat.ID, chat.ThreadID, roleID, weight)
+	if !ok {
+		return nil, false
+	}
+
+	persona := Persona{
+		RoleID:     role.ID,
+		Prompt:     role.Prompt,
+		MaxHistory: role.MaxHistory,
+	}
+	core.ai.StartChat(persona.chatKey(chat), persona.Prompt, persona.MaxHistory)
+
+	return role, true
+}
+
+func (core *Core) RemovePersona(chat ChatRef, roleID uint) bool {
+	return core.db.RemovePersona(chat.ID, chat.ThreadID, roleID)
+}
+
+func (core *Core) SetPersonaWeight(chat ChatRef, name string, weight int) bool {
+	for _, persona := range core.db.LoadPersonas(chat.ID, chat.ThreadID) {
+		if strings.EqualFold(persona.Name, name) {
+			return core.db.SetPersonaWeight(chat.ID, chat.ThreadID, persona.RoleID, weight)
+		}
+	}
+
+	return false
+}
+
+func (core *Core) SetPersonaAliases(chat ChatRef, arg string) bool {
+	personas := core.db.LoadPersonas(chat.ID, chat.ThreadID)
+	names := make([]string, 0, len(personas))
+	for _, persona := range personas {
+		names = append(names, persona.Name)
+	}
+
+	name, text, ok := cutRoleName(arg, names)
+	if !ok || strings.TrimSpace(text) == "" {
+		return false
+	}
+
+	extra := ""
+	if strings.TrimSpace(text) != "-" {
+		aliases, ok := parseAliases(text)
+		if !ok {
+			return false
+		}
+		extra = strings.Join(aliases, ", ")
+	}
+
+	for _, persona := range personas {
+		if strings.EqualFold(persona.Name, name) {
+			return core.db.SetPersonaAliases(chat.ID, chat.ThreadID, persona.RoleID, extra)
+		}
+	}
+
+	return false
+}
+
+func (core *Core) PersonaList(chat ChatRef) string {
+	personas := core.db.LoadPersonas(chat.ID, chat.ThreadID)
+	if len(personas) == 0 {
+		return "No personas are active, I'm acting as a single persona."
+	}
+
+	var text strings.Builder
+	text.WriteString("Active personas:")
+	for _, persona := range personas {
+		text.WriteString(fmt.Sprintf("\n%d: %s (nickname %s, weight %d)",
+			persona.RoleID, persona.Name, persona.Nickname, persona.Weight))
+		if persona.Aliases != "" {
+			text.WriteString(", also called " + persona.Aliases)
+		}
+	}
+
+	return text.String()
+}
@@ -0,0 +1,82 @@
+package zaya
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxPromptSize     = 64 << 10
+	promptWarnPercent = 50
+	promptMaxPercent  = 80
+)
+
+func promptFromFile(name string, data []byte) (string, error) {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".txt", ".md":
+	default:
+		return "", errors.New("only .txt and .md documents are supported")
+	}
+
+	if len(data) > maxPromptSize {
+		return "", errors.New("the document is too large")
+	}
+
+	if !utf8.Valid(data) {
+		return "", errors.New("the document is not a UTF-8 text")
+	}
+
+	prompt := strings.TrimSpace(strings.TrimPrefix(string(data), "\ufeff"))
+	if prompt == "" {
+		return "", errors.New("the document is empty")
+	}
+
+	return prompt, nil
+}
+
+func (core *Core) promptFits(prompt string) bool {
+	return core.ai.maxCtx <= 0 || len(prompt)*100 <= core.ai.maxCtx*promptMaxPercent
+}
+
+func (core *Core) promptShare(prompt string) int {
+	return (len(prompt)*100 + core.ai.maxCtx - 1) / core.ai.maxCtx
+}
+
+func (core *Core) ChangePrompt(chat ChatRef, prompt string) string {
+	prompt = strings.TrimSpace(prompt)
+	if prompt == "" {
+		return "Example usage: set_prompt You are a helpful assistant\n" +
+			"To set a long prompt, reply with set_prompt to a text or to a .txt or .md document."
+	}
+
+	if !core.promptFits(prompt) {
+		return fmt.Sprintf("The prompt takes %d%% of the context and leaves too little room "+
+			"for the conversation, make it shorter.", core.promptShare(prompt))
+	}
+
+	core.SetPrompt(chat, prompt)
+
+	if core.ai.maxCtx <= 0 {
+		return "System prompt changed."
+	}
+
+	share := core.promptShare(prompt)
+	text := fmt.Sprintf("System prompt changed, it takes %d%% of the context.", share)
+	if share > promptWarnPercent {
+		text += " That leaves little room for the conversation, so I'll forget older messages quickly."
+	}
+
+	return text
+}
+
+func (core *Core) ChangePromptFile(chat ChatRef, name string, data []byte) string {
+	prompt, err := promptFromFile(name, data)
+	if err != nil {
+		return "Can't use the document as a prompt: " + err.Error() + "."
+	}
+
+	return core.ChangePrompt(chat, prompt)
+}
@@ -0,0 +1,65 @@
+package zaya
+
+import (
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestPromptFromFile(t *testing.T) {
+	prompt, err := promptFromFile("Pirate.MD", []byte("\ufeff# Pirate\n\nSpeak like a pirate.\n"))
+	require.NoError(t, err)
+	require.Equal(t, "# Pirate\n\nSpeak like a pirate.", prompt)
+
+	_, err = promptFromFile("prompt.pdf", []byte("text"))
+	require.Error(t, err)
+
+	_, err = promptFromFile("prompt.txt", []byte(" \n"))
+	require.Error(t, err)
+
+	_, err = promptFromFile("prompt.txt", []byte{0xff, 0xfe})
+	require.Error(t, err)
+
+	_, err = promptFromFile("prompt.txt", make([]byte, maxPromptSize+1))
+	require.Error(t, err)
+}
+
+func TestChangePrompt(t *testing.T) {
+	core, _ := setupTestCore(t)
+	chat := ChatRef{ID: 1, Private: true}
+
+	text := core.ChangePrompt(chat, " ")
+	require.Contains(t, text, "Example usage")
+
+	text = core.ChangePrompt(chat, "be brief")
+	require.Equal(t, "System prompt changed, it takes 1% of the context.", text)
+	require.Equal(t, "be brief", core.LoadConfig(chat).Prompt)
+
+	long := strings.Repeat("a", 600)
+	text = core.ChangePrompt(chat, long)
+	require.Contains(t, text, "takes 60% of the context")
+	require.Contains(t, text, "little room")
+	require.Equal(t, long, core.LoadConfig(chat).Prompt)
+
+	text = core.ChangePrompt(chat, strings.Repeat("a", 900))
+	require.Contains(t, text, "takes 90% of the context")
+	require.Contains(t, text, "make it shorter")
+	require.Equal(t, long, core.LoadConfig(chat).Prompt)
+
+	text = core.ChangePromptFile(chat, "prompt.md", []byte("line one\nline two\n"))
+	require.Contains(t, text, "System prompt changed")
+	require.Equal(t, "line one\nline two", core.LoadConfig(chat).Prompt)
+
+	text = core.ChangePromptFile(chat, "prompt.docx", []byte("text"))
+	require.Contains(t, text, "only .txt and .md")
+
+	core.db.SetPrompt(chat.ID, strings.Repeat("a", 900))
+	core.db.SaveRole(chat.ID, "en", "Verbose")
+	roles := core.db.LoadChatRoleNames(chat.ID)
+	require.Len(t, roles, 1)
+
+	_, ok := core.SetRole(chat, roles[0].ID)
+	require.False(t, ok)
+	_, ok = core.AddPersona(chat, roles[0].ID, 1)
+	require.False(t, ok)
+}
@@ -0,0 +1,262 @@
+package zaya
+
+import (
+	"fmt"
+	"github.com/erni27/imcache"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var limitColumns = map[string]string{
+	"user_rate":  "user_rate",
+	"user_burst": "user_burst",
+	"user_daily": "user_daily",
+	"chat_rate":  "chat_rate",
+	"chat_burst": "chat_burst",
+	"chat_daily": "chat_daily",
+}
+
+type quotaSubject struct {
+	name  string
+	title string
+	rate  float64
+	burst int
+	daily int
+}
+
+func (core *Core) chatLimits(chatID int64) LimitsConfig {
+	limits := core.limits
+
+	override := core.db.LoadChatLimit(chatID)
+	if override.UserRate != nil {
+		limits.UserRate = *override.UserRate
+	}
+	if override.UserBurst != nil {
+		limits.UserBurst = *override.UserBurst
+	}
+	if override.UserDaily != nil {
+		limits.UserDaily = *override.UserDaily
+	}
+	if override.ChatRate != nil {
+		limits.ChatRate = *override.ChatRate
+	}
+	if override.ChatBurst != nil {
+		limits.ChatBurst = *override.ChatBurst
+	}
+	if override.ChatDaily != nil {
+		limits.ChatDaily = *override.ChatDaily
+	}
+
+	return limits
+}
+
+func (core *Core) quotaSubjects(chat ChatRef, userID int64) []quotaSubject {
+	limits := core.chatLimits(chat.ID)
+
+	subjects := []quotaSubject{{
+		name:  fmt.Sprintf("chat:%d", chat.ID),
+		title: "this chat",
+		rate:  limits.ChatRate,
+		burst: max(limits.ChatBurst, 1),
+		daily: limits.ChatDaily,
+	}}
+
+	if userID != 0 {
+		subjects = append(subjects, quotaSubject{
+			name:  fmt.Sprintf("user:%d", userID),
+			title: "you",
+			rate:  limits.UserRate,
+			burst: max(limits.UserBurst, 1),
+			daily: limits.UserDaily,
+		})
+	}
+
+	return subjects
+}
+
+func refillUsage(usage *UsageRecord, subject quotaSubject, now time.Time) {
+	day := now.UTC().Format(time.DateOnly)
+	if usage.Day != day {
+		usage.Day = day
+		usage.Tokens = 0
+	}
+
+	if usage.BucketAt.IsZero() {
+		usage.Bucket = float64(subject.burst)
+	} else {
+		elapsed := now.Sub(usage.BucketAt).Minutes()
+		usage.Bucket = math.Min(float64(subject.burst), usage.Bucket+elapsed*subject.rate)
+	}
+	usage.BucketAt = now
+}
+
+func quotaReset(now time.Time) time.Time {
+	day := now.UTC()
+	return time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, time.UTC)
+}
+
+func (core *Core) checkQuota(chat ChatRef, userID int64, now time.Time) (string, bool) {
+	return core.takeQuota(chat, userID, now, true)
+}
+
+func (core *Core) hasTokens(chat ChatRef, userID int64) bool {
+	_, ok := core.takeQuota(chat, userID, time.Now(), false)
+	return ok
+}
+
+func (core *Core) takeQuota(chat ChatRef, userID int64, now time.Time, consume bool) (string, bool) {
+	core.quotaLock.Lock()
+	defer core.quotaLock.Unlock()
+
+	subjects := core.quotaSubjects(chat, userID)
+	usages := make([]UsageRecord, len(subjects))
+
+	for i, subject := range subjects {
+		usages[i] = core.db.LoadUsage(subject.name)
+		refillUsage(&usages[i], subject, now)
+
+		if subject.daily > 0 && usages[i].Tokens >= subject.daily {
+			reset := quotaReset(now).In(core.Location(chat))
+			return fmt.Sprintf("The daily quota of %d tokens for %s is used up. It resets at %s.",
+				subject.daily, subject.title, reset.Format(reminderTimeLayout)), false
+		}
+
+		if consume && subject.rate > 0 && usages[i].Bucket < 1 {
+			wait := time.Duration((1 - usages[i].Bucket) / subject.rate * float64(time.Minute))
+			return fmt.Sprintf("Too many messages from %s, please wait %s.",
+				subject.title, wait.Round(time.Second)), false
+		}
+	}
+
+	if !consume {
+		return "", true
+	}
+
+	for i, subject := range subjects {
+		if subject.rate > 0 {
+			usages[i].Bucket--
+		}
+		core.db.SaveUsage(&usages[i])
+	}
+
+	return "", true
+}
+
+func (core *Core) chargeQuota(chat ChatRef, userID int64, tokens int) {
+	if tokens <= 0 {
+		return
+	}
+
+	core.quotaLock.Lock()
+	defer core.quotaLock.Unlock()
+
+	now := time.Now()
+	for _, subject := range core.quotaSubjects(chat, userID) {
+		usage := core.db.LoadUsage(subject.name)
+		refillUsage(&usage, subject, now)
+		usage.Tokens += tokens
+		core.db.SaveUsage(&usage)
+	}
+}
+
+func (core *Core) allowReply(fe Frontend, msg *Message, notify bool) bool {
+	notice, ok := core.checkQuota(msg.Chat, msg.SenderID, time.Now())
+	if ok {
+		return true
+	}
+
+	core.log.Infow("quota exceeded", "chat_id", msg.Chat.ID, "user_id", msg.SenderID)
+	zayaMetrics.quotaRejections.Inc()
+	if !notify {
+		return false
+	}
+
+	if core.takeQuotaNotice(msg.Chat) {
+		_, err := fe.Send(msg.Chat, msg, notice, SendOptions{})
+		if err != nil {
+			core.log.Warnw(err.Error(), "chat_id", msg.Chat.ID)
+		}
+	}
+
+	return false
+}
+
+func (core *Core) takeQuotaNotice(chat ChatRef) bool {
+	core.quotaLock.Lock()
+	defer core.quotaLock.Unlock()
+
+	_, notified := core.quotaNotices.Get(chat.Key())
+	if notified {
+		return false
+	}
+
+	core.quotaNotices.Set(chat.Key(), struct{}{}, imcache.WithExpiration(time.Minute))
+	return true
+}
+
+func (core *Core) QuotaInfo(chat ChatRef, userID int64) string {
+	core.quotaLock.Lock()
+	defer core.quotaLock.Unlock()
+
+	now := time.Now()
+	reset := quotaReset(now).In(core.Location(chat))
+
+	var text strings.Builder
+	for _, subject := range core.quotaSubjects(chat, userID) {
+		usage := core.db.LoadUsage(subject.name)
+		refillUsage(&usage, subject, now)
+
+		if subject.title == "you" {
+			text.WriteString("Your quota:")
+		} else {
+			text.WriteString("Chat quota:")
+		}
+
+		if subject.daily > 0 {
+			text.WriteString(fmt.Sprintf("\n%d of %d tokens left today, resets at %s",
+				max(subject.daily-usage.Tokens, 0), subject.daily, reset.Format(reminderTimeLayout)))
+		} else {
+			text.WriteString(fmt.Sprintf("\n%d tokens used today, no daily limit", usage.Tokens))
+		}
+
+		if subject.rate > 0 {
+			text.WriteString(fmt.Sprintf("\n%d of %d messages available right now, %.1f more every minute",
+				int(usage.Bucket), subject.burst, subject.rate))
+		} else {
+			text.WriteString("\nno message rate limit")
+		}
+
+		text.WriteString("\n\n")
+	}
+
+	return strings.TrimSpace(text.String())
+}
+
+func (core *Core) SetChatLimit(chat ChatRef, name, value string) bool {
+	column, ok := limitColumns[name]
+	if !ok {
+		return false
+	}
+
+	if value == "default" {
+		return core.db.SetChatLimit(chat.ID, column, nil)
+	}
+
+	if strings.HasSuffix(name, "_rate") {
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate < 0 {
+			return false
+		}
+
+		return core.db.SetChatLimit(chat.ID, column, rate)
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return false
+	}
+
+	return core.db.SetChatLimit(chat.ID, column, n)
+}
@@ -0,0 +1,150 @@
+package zaya
+
+import (
+	"github.com/erni27/imcache"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuotaRate(t *testing.T) {
+	core, _ := setupTestCore(t)
+	core.limits = LimitsConfig{UserRate: 1, UserBurst: 2}
+	fe := &testFrontend{}
+	user := ChatRef{ID: 1, Private: true}
+
+	for i := 0; i < 3; i++ {
+		_, err := core.ReadMessage(fe, &Message{Chat: user, SenderID: 1, Text: "hi"})
+		require.NoError(t, err)
+	}
+	_, err := core.ReadMessage(fe, &Message{Chat: user, SenderID: 1, Text: "hi"})
+	require.NoError(t, err)
+
+	require.Len(t, fe.sent, 3)
+	require.Equal(t, "re: hi", fe.sent[1].Text)
+	require.True(t, strings.HasPrefix(fe.sent[2].Text, "Too many messages from you, please wait "), fe.sent[2].Text)
+
+	_, ok := core.checkQuota(user, 1, time.Now())
+	require.False(t, ok)
+	_, ok = core.checkQuota(user, 1, time.Now().Add(time.Minute))
+	require.True(t, ok)
+
+	text, _ := core.RunCommand(fe, &Message{Chat: user, SenderID: 1}, "set_limit", "user_rate 0")
+	require.Equal(t, "Only the bot admin can change limits.", text)
+
+	core.adminID = 1
+	text, _ = core.RunCommand(fe, &Message{Chat: user, SenderID: 1}, "set_limit", "user_rate x")
+	require.True(t, strings.HasPrefix(text, "Example usage"))
+	text, _ = core.RunCommand(fe, &Message{Chat: user, SenderID: 1}, "set_limit", "user_rate 0")
+	require.Equal(t, "Limit changed.", text)
+
+	_, ok = core.checkQuota(user, 1, time.Now())
+	require.True(t, ok)
+
+	text, _ = core.RunCommand(fe, &Message{Chat: user, SenderID: 1}, "set_limit", "user_rate default")
+	require.Equal(t, "Limit changed.", text)
+	require.Equal(t, 1.0, core.chatLimits(user.ID).UserRate)
+}
+
+func TestQuotaDaily(t *testing.T) {
+	core, _ := setupTestCore(t)
+	core.limits = LimitsConfig{ChatDaily: 10}
+	fe := &testFrontend{}
+	group := ChatRef{ID: -1}
+	core.db.SetFreq(group.ID, 0)
+
+	_, err := core.ReadMessage(fe, &Message{Chat: group, SenderID: 1, Text: "hey @test_bot"})
+	require.NoError(t, err)
+	require.Len(t, fe.sent, 1)
+
+	info := core.QuotaInfo(group, 1)
+	require.Contains(t, info, "Chat quota:\n0 of 10 tokens left today")
+	require.Contains(t, info, "Your quota:\n")
+
+	restarted := NewCore(core.ai, core.db, Config{Limits: core.limits})
+	notice, ok := restarted.checkQuota(group, 2, time.Now())
+	require.False(t, ok)
+	require.True(t, strings.HasPrefix(notice, "The daily quota of 10 tokens for this chat is used up."), notice)
+
+	_, ok = restarted.checkQuota(group, 2, time.Now().Add(24*time.Hour))
+	require.True(t, ok)
+}
+
+func TestQuotaChimeIn(t *testing.T) {
+	core, _ := setupTestCore(t)
+	core.limits = LimitsConfig{UserRate: 1, UserBurst: 1}
+	fe := &testFrontend{}
+	group := ChatRef{ID: -1}
+	core.db.SetFreq(group.ID, 100)
+
+	replied, err := core.ReadMessage(fe, &Message{Chat: group, SenderID: 1, Text: "hi all"})
+	require.NoError(t, err)
+	require.True(t, replied)
+	require.Len(t, fe.sent, 1)
+
+	replied, err = core.ReadMessage(fe, &Message{Chat: group, SenderID: 1, Text: "how are you?"})
+	require.NoError(t, err)
+	require.False(t, replied)
+	require.Len(t, fe.sent, 1)
+
+	_, err = core.ReadMessage(fe, &Message{Chat: group, SenderID: 1, Text: "hey @test_bot"})
+	require.NoError(t, err)
+	require.Len(t, fe.sent, 2)
+	require.True(t, strings.HasPrefix(fe.sent[1].Text, "Too many messages from you, please wait "), fe.sent[1].Text)
+
+	_, err = core.ReadMessage(fe, &Message{Chat: group, SenderID: 1, Text: "hey @test_bot"})
+	require.NoError(t, err)
+	require.Len(t, fe.sent, 2)
+
+	core.quotaNotices.Set(group.Key(), struct{}{}, imcache.WithExpiration(time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	_, err = core.ReadMessage(fe, &Message{Chat: group, SenderID: 1, Text: "hey @test_bot"})
+	require.NoError(t, err)
+	require.Len(t, fe.sent, 3)
+}
+
+func TestQuotaGenerationPaths(t *testing.T) {
+	core, _ := setupTestCore(t)
+	core.limits = LimitsConfig{UserDaily: 10}
+	core.inlineDebounce = 0
+	fe := &testFrontend{}
+	group := ChatRef{ID: -1}
+	core.db.SetFreq(group.ID, 0)
+
+	answer, ok := core.AnswerInline(5, "what is love?")
+	require.True(t, ok)
+	require.Equal(t, "re: what is love?", answer)
+	require.Positive(t, core.db.LoadUsage("user:5").Tokens)
+
+	_, ok = core.AnswerInline(5, "what is hate?")
+	require.False(t, ok)
+
+	core.bufferMessage(group, "alice", "let's talk")
+	text := core.Summarize(fe, &Message{Chat: group, SenderID: 1}, "")
+	require.Empty(t, text)
+	require.Positive(t, core.db.LoadUsage("user:1").Tokens)
+
+	text = core.Summarize(fe, &Message{Chat: group, SenderID: 1}, "")
+	require.True(t, strings.HasPrefix(text, "The daily quota of 10 tokens for you is used up."), text)
+
+	core.limits = LimitsConfig{ChatDaily: 10}
+	core.debateDelay = time.Millisecond
+	core.db.SetPrompt(group.ID, "socrat prompt")
+	core.db.SaveRole(group.ID, "en", "Socrat")
+	core.db.SetPrompt(group.ID, "lunatic prompt")
+	core.db.SaveRole(group.ID, "en", "Lunatic")
+	fe = &testFrontend{}
+
+	text, _ = core.RunCommand(fe, &Message{Chat: group, SenderID: 2}, "debate", "Socrat Lunatic cheese 4")
+	require.Contains(t, text, "going to debate")
+	require.Eventually(t, func() bool {
+		return !core.IsDebating(group)
+	}, time.Second, time.Millisecond)
+
+	sent := fe.sentTexts()
+	require.Len(t, sent, 1)
+	require.True(t, strings.HasPrefix(sent[0], "The debate was interrupted. The daily quota of 10 tokens for this chat"), sent[0])
+
+	require.Error(t, core.sendInitiative(fe, group))
+}
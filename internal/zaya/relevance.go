@@ -0,0 +1,170 @@
+package zaya
+
+import (
+	"fmt"
+	"github.com/erni27/imcache"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	relevanceHeuristic = "heuristic"
+	relevanceModel     = "model"
+	relevanceHistory   = 10
+)
+
+const relevancePrompt = "" +
+	"You decide whether a chat bot should join a group conversation uninvited. " +
+	"The bot plays this persona:\n%s\n\n" +
+	"Rate from 0 to 100 how relevant and welcome a reply from the bot to the last message would be. " +
+	"Questions to everyone and topics the persona cares about score high, " +
+	"small talk between other people scores low. Answer with a number only."
+
+var (
+	questionWordsRe = regexp.MustCompile(`(?i)(^|[^\p{L}])(who|what|how|why|where|when|which|anyone|somebody|` +
+		`кто|что|как|почему|зачем|где|когда|какой|какая|какие|сколько|кто-нибудь|подскажите)([^\p{L}]|$)`)
+	scoreRe = regexp.MustCompile(`\d+`)
+)
+
+func promptWords(prompt string) map[string]struct{} {
+	words := make(map[string]struct{})
+	for _, word := range splitWords(prompt) {
+		if utf8.RuneCountInString(word) >= 4 {
+			words[wordStem(word)] = struct{}{}
+		}
+	}
+
+	return words
+}
+
+func relevanceScore(text, prompt string, recent []bufferedMessage, botName string) int {
+	score := 0
+
+	if strings.Contains(text, "?") {
+		score += 30
+	}
+
+	if questionWordsRe.MatchString(text) {
+		score += 15
+	}
+
+	words := splitWords(text)
+	if len(words) < 3 {
+		score -= 20
+	}
+
+	topics := promptWords(prompt)
+	overlap := 0
+	for _, word := range words {
+		if utf8.RuneCountInString(word) < 4 {
+			continue
+		}
+
+		_, ok := topics[wordStem(word)]
+		if ok {
+			overlap++
+		}
+	}
+	score += min(overlap*10, 40)
+
+	for _, msg := range recent[max(len(recent)-relevanceHistory, 0):] {
+		if msg.sender == botName {
+			score += 20
+			break
+		}
+	}
+
+	return max(min(score, 100), 0)
+}
+
+func parseRelevanceScore(answer string) (int, bool) {
+	score, err := strconv.Atoi(scoreRe.FindString(answer))
+	if err != nil || score > 100 {
+		return 0, false
+	}
+
+	return score, true
+}
+
+func (core *Core) modelRelevance(msg *Message, persona Persona, recent []bufferedMessage) (int, bool) {
+	chat := msg.Chat
+	if !core.hasTokens(chat, msg.SenderID) {
+		return 0, false
+	}
+
+	transcript := summaryTranscript(recent[max(len(recent)-relevanceHistory, 0):], core.Location(chat))
+	prompt := fmt.Sprintf(relevancePrompt, truncateText(persona.Prompt, 1000))
+
+	var answer AIReply
+	ok := false
+	core.scheduler.run(chat, priorityLow, func() {
+		answer, ok = core.ai.Complete(chat.Key(), prompt, transcript, true)
+	})
+	core.chargeQuota(chat, msg.SenderID, answer.CtxLen+answer.ReplyLen)
+	if !ok {
+		return 0, false
+	}
+
+	return parseRelevanceScore(answer.Text)
+}
+
+func (core *Core) chimeIn(msg *Message, cfg *ChatConfig, personas []Persona) (Persona, bool) {
+	_, cooling := core.cooldowns.Get(msg.Chat.Key())
+	if cooling {
+		zayaMetrics.relevanceChecks.WithLabelValues("cooldown").Inc()
+		return Persona{}, false
+	}
+
+	recent := core.recentMessages(msg.Chat)
+
+	var best Persona
+	bestScore := -1
+	for _, persona := range personas {
+		if persona.Weight <= 0 {
+			continue
+		}
+
+		score := relevanceScore(msg.Text, persona.Prompt, recent, persona.senderName())
+		if score > bestScore {
+			best, bestScore = persona, score
+		}
+	}
+
+	if bestScore < 0 {
+		return Persona{}, false
+	}
+
+	if core.relevance.Mode == relevanceModel && bestScore*2 >= cfg.Relevance {
+		score, ok := core.modelRelevance(msg, best, recent)
+		if ok {
+			bestScore = score
+		}
+	}
+
+	core.log.Debugw("relevance", "chat_id", msg.Chat.ID, "score", bestScore, "threshold", cfg.Relevance)
+
+	if bestScore < cfg.Relevance {
+		zayaMetrics.relevanceChecks.WithLabelValues("skip").Inc()
+		return Persona{}, false
+	}
+
+	zayaMetrics.relevanceChecks.WithLabelValues("reply").Inc()
+
+	return best, true
+}
+
+func (core *Core) startCooldown(chat ChatRef) {
+	core.cooldowns.Set(chat.Key(), struct{}{}, imcache.WithExpiration(core.relevance.Cooldown))
+}
+
+func (core *Core) RelevanceInfo(chat ChatRef) string {
+	threshold := core.LoadConfig(chat).Relevance
+	if threshold <= 0 {
+		return "I chime in on random messages according to the frequency setting."
+	}
+
+	return fmt.Sprintf("I chime in when a message scores at least %d of 100 for relevance, "+
+		"at most once every %s.", threshold, core.relevance.Cooldown)
+}
@@ -0,0 +1,80 @@
+package zaya
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRelevanceScore(t *testing.T) {
+	prompt := "You are a gardener who loves roses, tomatoes and composting."
+	now := time.Now()
+
+	question := relevanceScore("How do you feed tomatoes in july?", prompt, nil, "bot")
+	smallTalk := relevanceScore("ok", prompt, nil, "bot")
+	statement := relevanceScore("my tomatoes and roses died again", prompt, nil, "bot")
+	require.Greater(t, question, statement)
+	require.Greater(t, statement, smallTalk)
+	require.Equal(t, 0, smallTalk)
+
+	recent := []bufferedMessage{{time: now, sender: "bot", text: "hi"}, {time: now, sender: "ann", text: "hello"}}
+	require.Equal(t, statement+20, relevanceScore("my tomatoes and roses died again", prompt, recent, "bot"))
+
+	score, ok := parseRelevanceScore("Score: 75")
+	require.True(t, ok)
+	require.Equal(t, 75, score)
+
+	_, ok = parseRelevanceScore("no idea")
+	require.False(t, ok)
+}
+
+func TestChimeIn(t *testing.T) {
+	core, llm := setupTestCore(t)
+	fe := &testFrontend{}
+	group := ChatRef{ID: -1}
+	core.db.SetFreq(group.ID, 0)
+	core.SetPrompt(group, "You are a gardener who loves roses and tomatoes.")
+
+	question := &Message{Chat: group, Text: "who knows how to grow tomatoes?"}
+	chatter := &Message{Chat: group, Text: "see you tomorrow at the office"}
+
+	_, reply, _ := core.ShouldReplyTo(fe, question)
+	require.False(t, reply)
+
+	text, _ := core.RunCommand(fe, &Message{Chat: group}, "set_relevance", "50")
+	require.Equal(t, "Relevance threshold changed.", text)
+	require.Equal(t, 50, core.LoadConfig(group).Relevance)
+
+	_, reply, _ = core.ShouldReplyTo(fe, chatter)
+	require.False(t, reply)
+
+	_, reply, forceKeep := core.ShouldReplyTo(fe, question)
+	require.True(t, reply)
+	require.False(t, forceKeep)
+
+	core.limits = LimitsConfig{ChatRate: 1, ChatBurst: 1}
+	_, ok := core.checkQuota(group, 0, time.Now())
+	require.True(t, ok)
+	replied, err := core.ReadMessage(fe, question)
+	require.NoError(t, err)
+	require.False(t, replied)
+	_, reply, _ = core.ShouldReplyTo(fe, question)
+	require.True(t, reply)
+
+	core.limits = LimitsConfig{}
+	replied, err = core.ReadMessage(fe, question)
+	require.NoError(t, err)
+	require.True(t, replied)
+	_, reply, _ = core.ShouldReplyTo(fe, question)
+	require.False(t, reply)
+
+	core.cooldowns.RemoveAll()
+	core.relevance.Mode = relevanceModel
+	llm.respond = func(_, _ string) string { return "20" }
+	_, reply, _ = core.ShouldReplyTo(fe, question)
+	require.False(t, reply)
+
+	llm.respond = func(_, _ string) string { return "90" }
+	_, reply, _ = core.ShouldReplyTo(fe, question)
+	require.True(t, reply)
+}
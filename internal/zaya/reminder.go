@@ -0,0 +1,330 @@
+package zaya
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata"
+)
+
+const (
+	maxChatReminders   = 50
+	maxReminderDelay   = 366 * 24 * time.Hour
+	reminderTimeLayout = "Mon, 02 Jan 15:04 MST"
+	reminderRetryDelay = time.Minute
+	reminderRetryMax   = time.Hour
+)
+
+const reminderPrompt = "" +
+	"You extract reminders from chat messages. The current local time is %s. " +
+	"If the message asks to remind about something at some time, answer with JSON like " +
+	`{"at": "2006-01-02 15:04", "text": "what to remind about"} ` +
+	"using the local time. Otherwise answer with {}. Answer with JSON only."
+
+var reminderWordsRe = regexp.MustCompile(`(?i)remind|напомн`)
+
+func (core *Core) AddFrontend(fe Frontend) {
+	core.frontendLock.Lock()
+	defer core.frontendLock.Unlock()
+
+	core.frontends[fe.Name()] = fe
+}
+
+func (core *Core) frontend(name string) (Frontend, bool) {
+	core.frontendLock.Lock()
+	defer core.frontendLock.Unlock()
+
+	fe, ok := core.frontends[name]
+	return fe, ok
+}
+
+func (core *Core) Location(chat ChatRef) *time.Location {
+	tz := core.db.LoadChatConfig(chat.ID).Timezone
+	if tz == "" {
+		return time.Local
+	}
+
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		core.log.Warnw(err.Error(), "chat_id", chat.ID)
+		return time.Local
+	}
+
+	return loc
+}
+
+func (core *Core) SetTimezone(chat ChatRef, tz string) bool {
+	if tz == "" || tz == "Local" {
+		return false
+	}
+
+	_, err := time.LoadLocation(tz)
+	if err != nil {
+		return false
+	}
+
+	core.db.SetTimezone(chat.ID, tz)
+	return true
+}
+
+func parseClock(text string) (int, int, bool) {
+	clock, err := time.Parse("15:04", text)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return clock.Hour(), clock.Minute(), true
+}
+
+func reminderAtClock(day time.Time, fields []string) (time.Time, []string) {
+	hour, minute := 9, 0
+	if len(fields) > 0 {
+		h, m, ok := parseClock(fields[0])
+		if ok {
+			hour, minute = h, m
+			fields = fields[1:]
+		}
+	}
+
+	at := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, day.Location())
+	return at, fields
+}
+
+func parseReminderDuration(text string) (time.Duration, bool) {
+	if days, ok := strings.CutSuffix(text, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, false
+		}
+
+		return time.Duration(n) * 24 * time.Hour, n > 0
+	}
+
+	dur, err := time.ParseDuration(text)
+	if err != nil {
+		return 0, false
+	}
+
+	return dur, dur > 0
+}
+
+func parseRemindArgs(arg string, now time.Time) (time.Time, string, bool) {
+	fields := strings.Fields(arg)
+	if len(fields) < 2 {
+		return time.Time{}, "", false
+	}
+
+	var at time.Time
+	rest := fields[1:]
+
+	if strings.EqualFold(fields[0], "in") {
+		dur, ok := parseReminderDuration(fields[1])
+		if !ok {
+			return time.Time{}, "", false
+		}
+
+		at = now.Add(dur)
+		rest = fields[2:]
+	} else if strings.EqualFold(fields[0], "tomorrow") {
+		at, rest = reminderAtClock(now.AddDate(0, 0, 1), fields[1:])
+	} else if day, err := time.ParseInLocation(time.DateOnly, fields[0], now.Location()); err == nil {
+		at, rest = reminderAtClock(day, fields[1:])
+	} else if hour, minute, ok := parseClock(fields[0]); ok {
+		at = time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+		if !at.After(now) {
+			at = at.AddDate(0, 0, 1)
+		}
+	} else {
+		return time.Time{}, "", false
+	}
+
+	if len(rest) == 0 || !at.After(now) || at.Sub(now) > maxReminderDelay {
+		return time.Time{}, "", false
+	}
+
+	return at, strings.Join(rest, " "), true
+}
+
+func parseExtractedReminder(answer string, now time.Time) (time.Time, string, bool) {
+	begin := strings.Index(answer, "{")
+	end := strings.LastIndex(answer, "}")
+	if begin < 0 || end < begin {
+		return time.Time{}, "", false
+	}
+
+	var extracted struct {
+		At   string `json:"at"`
+		Text string `json:"text"`
+	}
+
+	err := json.Unmarshal([]byte(answer[begin:end+1]), &extracted)
+	if err != nil || extracted.At == "" || strings.TrimSpace(extracted.Text) == "" {
+		return time.Time{}, "", false
+	}
+
+	at, err := time.ParseInLocation("2006-01-02 15:04", extracted.At, now.Location())
+	if err != nil || !at.After(now) || at.Sub(now) > maxReminderDelay {
+		return time.Time{}, "", false
+	}
+
+	return at, strings.TrimSpace(extracted.Text), true
+}
+
+func (core *Core) AddReminder(fe Frontend, msg *Message, at time.Time, text string) bool {
+	if core.db.CountReminders(msg.Chat.ID) >= maxChatReminders {
+		return false
+	}
+
+	return core.db.AddReminder(&Reminder{
+		ChatID:   msg.Chat.ID,
+		ThreadID: msg.Chat.ThreadID,
+		Frontend: fe.Name(),
+		UserID:   msg.SenderID,
+		UserName: msg.SenderName,
+		Text:     text,
+		DueAt:    at.UTC(),
+	})
+}
+
+func (core *Core) Remind(fe Frontend, msg *Message, arg string) string {
+	const usage = "" +
+		"Example usage: remind tomorrow 9:00 call mom\n" +
+		"The time may be: in 30m, in 2h, in 3d, 18:30, tomorrow [9:00], 2030-01-31 [9:00]."
+
+	now := time.Now().In(core.Location(msg.Chat))
+	at, text, ok := parseRemindArgs(arg, now)
+	if !ok {
+		return usage
+	}
+
+	if !core.AddReminder(fe, msg, at, text) {
+		return "Can't save the reminder, there are too many of them in this chat."
+	}
+
+	return fmt.Sprintf("I'll remind you on %s.", at.Format(reminderTimeLayout))
+}
+
+func (core *Core) ReminderList(chat ChatRef) string {
+	reminders := core.db.LoadReminders(chat.ID, chat.ThreadID)
+	if len(reminders) == 0 {
+		return "There are no reminders."
+	}
+
+	loc := core.Location(chat)
+
+	var text strings.Builder
+	text.WriteString("Reminders:")
+	for _, reminder := range reminders {
+		text.WriteString(fmt.Sprintf("\n%d: %s - %s",
+			reminder.ID, reminder.DueAt.In(loc).Format(reminderTimeLayout), reminder.Text))
+	}
+
+	return text.String()
+}
+
+func (core *Core) extractReminder(fe Frontend, msg *Message, text string) {
+	if !reminderWordsRe.MatchString(text) {
+		return
+	}
+
+	if !core.hasTokens(msg.Chat, msg.SenderID) {
+		return
+	}
+
+	now := time.Now().In(core.Location(msg.Chat))
+	prompt := fmt.Sprintf(reminderPrompt, now.Format("2006-01-02 15:04, Monday"))
+
+	var answer AIReply
+	ok := false
+	core.scheduler.run(msg.Chat, jobPriority(fe, msg), func() {
+		answer, ok = core.ai.Complete(msg.Chat.Key(), prompt, text, false)
+	})
+	core.chargeQuota(msg.Chat, msg.SenderID, answer.CtxLen+answer.ReplyLen)
+	if !ok {
+		return
+	}
+
+	at, what, ok := parseExtractedReminder(answer.Text, now)
+	if !ok || !core.AddReminder(fe, msg, at, what) {
+		return
+	}
+
+	core.log.Infow("reminder extracted", "chat_id", msg.Chat.ID, "thread_id", msg.Chat.ThreadID)
+
+	text = fmt.Sprintf("Reminder set for %s: %s", at.Format(reminderTimeLayout), what)
+	_, err := fe.Send(msg.Chat, msg, text, SendOptions{})
+	if err != nil {
+		core.log.Warnw(err.Error(), "chat_id", msg.Chat.ID)
+	}
+}
+
+func reminderText(reminder *Reminder) string {
+	if reminder.UserName == "" {
+		return "Reminder: " + reminder.Text
+	}
+
+	mention := reminder.UserName
+	if !strings.HasPrefix(mention, "@") {
+		mention = "@" + mention
+	}
+
+	return mention + ", reminder: " + reminder.Text
+}
+
+func (core *Core) runReminders() {
+	defer core.wg.Done()
+
+	ticker := time.NewTicker(core.reminderInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-core.stop:
+			return
+		case now := <-ticker.C:
+			core.dispatchReminders(now)
+		}
+	}
+}
+
+func (core *Core) dispatchReminders(now time.Time) {
+	for _, reminder := range core.db.LoadDueReminders(now) {
+		fe, ok := core.frontend(reminder.Frontend)
+		if !ok {
+			continue
+		}
+
+		chat := ChatRef{ID: reminder.ChatID, ThreadID: reminder.ThreadID}
+		_, err := fe.Send(chat, nil, reminderText(&reminder), SendOptions{})
+		if err != nil && !errors.Is(err, ErrChatUnavailable) {
+			attempts := reminder.Attempts + 1
+			retryAt := now.Add(reminderBackoff(attempts))
+			core.log.Warnw(err.Error(),
+				"chat_id", reminder.ChatID,
+				"reminder_id", reminder.ID,
+				"attempts", attempts,
+				"retry_at", retryAt)
+			core.db.DelayReminder(reminder.ID, attempts, retryAt)
+			continue
+		}
+
+		if err != nil {
+			core.log.Warnw(err.Error(), "chat_id", reminder.ChatID, "reminder_id", reminder.ID)
+		}
+
+		core.db.RemoveReminder(reminder.ChatID, reminder.ID)
+	}
+}
+
+func reminderBackoff(attempts int) time.Duration {
+	delay := reminderRetryDelay
+	for i := 1; i < attempts && delay < reminderRetryMax; i++ {
+		delay *= 2
+	}
+
+	return min(delay, reminderRetryMax)
+}
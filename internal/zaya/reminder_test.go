@@ -0,0 +1,148 @@
+package zaya
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRemindArgs(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+	now := time.Date(2030, 1, 31, 12, 0, 0, 0, loc)
+
+	tests := []struct {
+		arg  string
+		at   time.Time
+		text string
+	}{
+		{"in 30m drink water", now.Add(30 * time.Minute), "drink water"},
+		{"in 2d pay rent", now.AddDate(0, 0, 2), "pay rent"},
+		{"tomorrow call mom", time.Date(2030, 2, 1, 9, 0, 0, 0, loc), "call mom"},
+		{"tomorrow 18:30 call mom", time.Date(2030, 2, 1, 18, 30, 0, 0, loc), "call mom"},
+		{"13:15 lunch", time.Date(2030, 1, 31, 13, 15, 0, 0, loc), "lunch"},
+		{"11:00 standup", time.Date(2030, 2, 1, 11, 0, 0, 0, loc), "standup"},
+		{"2030-03-08 flowers", time.Date(2030, 3, 8, 9, 0, 0, 0, loc), "flowers"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.arg, func(t *testing.T) {
+			at, text, ok := parseRemindArgs(tt.arg, now)
+			require.True(t, ok)
+			require.True(t, tt.at.Equal(at), at.String())
+			require.Equal(t, tt.text, text)
+		})
+	}
+
+	for _, arg := range []string{"", "tomorrow", "in 10m", "in -1h x", "2020-01-01 x", "2032-01-01 x", "soon x"} {
+		_, _, ok := parseRemindArgs(arg, now)
+		require.False(t, ok, arg)
+	}
+}
+
+func TestParseExtractedReminder(t *testing.T) {
+	now := time.Date(2030, 1, 31, 12, 0, 0, 0, time.UTC)
+
+	at, text, ok := parseExtractedReminder("Sure: {\"at\": \"2030-02-01 09:00\", \"text\": \"call mom\"}", now)
+	require.True(t, ok)
+	require.Equal(t, time.Date(2030, 2, 1, 9, 0, 0, 0, time.UTC), at)
+	require.Equal(t, "call mom", text)
+
+	for _, answer := range []string{"{}", "no", `{"at": "2030-01-01 09:00", "text": "x"}`, `{"at": "tomorrow", "text": "x"}`} {
+		_, _, ok = parseExtractedReminder(answer, now)
+		require.False(t, ok, answer)
+	}
+}
+
+func TestReminders(t *testing.T) {
+	core, llm := setupTestCore(t)
+	fe := &testFrontend{}
+	core.AddFrontend(fe)
+
+	chat := ChatRef{ID: 1, Private: true}
+	msg := &Message{Chat: chat, SenderID: 1, SenderName: "alice"}
+
+	text, _ := core.RunCommand(fe, msg, "set_timezone", "Mars/Olympus")
+	require.Equal(t, "Example usage: set_timezone Europe/Moscow", text)
+	text, _ = core.RunCommand(fe, msg, "set_timezone", "Asia/Tokyo")
+	require.Equal(t, "Timezone changed.", text)
+	require.Equal(t, "Asia/Tokyo", core.Location(chat).String())
+
+	text, _ = core.RunCommand(fe, msg, "remind", "in 1h call mom")
+	require.True(t, strings.HasPrefix(text, "I'll remind you on "), text)
+	require.True(t, strings.HasSuffix(text, "JST."), text)
+
+	llm.respond = func(prompt, text string) string {
+		if strings.HasPrefix(prompt, "You extract reminders") {
+			at := time.Now().In(core.Location(chat)).Add(2 * time.Hour)
+			return `{"at": "` + at.Format("2006-01-02 15:04") + `", "text": "feed the cat"}`
+		}
+		return "sure, I will"
+	}
+
+	_, err := core.ReadMessage(fe, &Message{Chat: chat, SenderID: 1, SenderName: "alice", Text: "remind me to feed the cat"})
+	require.NoError(t, err)
+	require.Len(t, fe.sent, 2)
+	require.Equal(t, "sure, I will", fe.sent[0].Text)
+	require.True(t, strings.HasPrefix(fe.sent[1].Text, "Reminder set for "), fe.sent[1].Text)
+
+	text, _ = core.RunCommand(fe, msg, "reminders", "")
+	require.Contains(t, text, "call mom")
+	require.Contains(t, text, "feed the cat")
+
+	core.dispatchReminders(time.Now())
+	require.Len(t, fe.sent, 2)
+
+	core.dispatchReminders(time.Now().Add(90 * time.Minute))
+	require.Len(t, fe.sent, 3)
+	require.Equal(t, "@alice, reminder: call mom", fe.sent[2].Text)
+
+	reminders := core.db.LoadReminders(chat.ID, 0)
+	require.Len(t, reminders, 1)
+
+	text, _ = core.RunCommand(fe, msg, "cancel_reminder", "100")
+	require.Equal(t, "There is no such reminder.", text)
+	text, _ = core.RunCommand(fe, msg, "cancel_reminder", strconv.Itoa(int(reminders[0].ID)))
+	require.Equal(t, "Reminder cancelled.", text)
+	require.Equal(t, "There are no reminders.", core.ReminderList(chat))
+}
+
+func TestReminderRetry(t *testing.T) {
+	core, _ := setupTestCore(t)
+	fe := &testFrontend{err: errors.New("timeout")}
+	core.AddFrontend(fe)
+
+	chat := ChatRef{ID: 1, Private: true}
+	msg := &Message{Chat: chat, SenderID: 1}
+	now := time.Now()
+	require.True(t, core.AddReminder(fe, msg, now.Add(-time.Minute), "call mom"))
+
+	core.dispatchReminders(now)
+	reminders := core.db.LoadReminders(chat.ID, 0)
+	require.Len(t, reminders, 1)
+	require.Equal(t, 1, reminders[0].Attempts)
+
+	core.dispatchReminders(now.Add(30 * time.Second))
+	require.Equal(t, 1, core.db.LoadReminders(chat.ID, 0)[0].Attempts)
+
+	core.dispatchReminders(now.Add(time.Minute))
+	require.Equal(t, 2, core.db.LoadReminders(chat.ID, 0)[0].Attempts)
+
+	fe.err = nil
+	core.dispatchReminders(now.Add(3 * time.Minute))
+	require.Len(t, fe.sent, 1)
+	require.Empty(t, core.db.LoadReminders(chat.ID, 0))
+
+	require.True(t, core.AddReminder(fe, msg, now.Add(-time.Minute), "feed the cat"))
+	fe.err = fmt.Errorf("%w: blocked", ErrChatUnavailable)
+	core.dispatchReminders(now)
+	require.Empty(t, core.db.LoadReminders(chat.ID, 0))
+
+	require.Equal(t, time.Minute, reminderBackoff(1))
+	require.Equal(t, 4*time.Minute, reminderBackoff(3))
+	require.Equal(t, time.Hour, reminderBackoff(20))
+}
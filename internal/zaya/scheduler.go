@@ -0,0 +1,230 @@
+package zaya
+
+import (
+	"slices"
+	"strings"
+	"sync"
+)
+
+const defaultWorkers = 4
+
+const (
+	priorityHigh = iota
+	priorityLow
+	priorityLevels
+)
+
+type genJob struct {
+	chat     ChatKey
+	priority int
+	run      func()
+	done     chan struct{}
+}
+
+type scheduler struct {
+	lock    sync.Mutex
+	cond    *sync.Cond
+	queues  [priorityLevels]map[ChatKey][]*genJob
+	rounds  [priorityLevels][]ChatKey
+	running map[ChatKey]bool
+	pending int
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+func newScheduler(workers int) *scheduler {
+	s := &scheduler{running: make(map[ChatKey]bool)}
+	s.cond = sync.NewCond(&s.lock)
+	for level := range s.queues {
+		s.queues[level] = make(map[ChatKey][]*genJob)
+	}
+
+	s.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go s.work()
+	}
+
+	return s
+}
+
+func jobPriority(fe Frontend, msg *Message) int {
+	if msg.Chat.Private {
+		return priorityHigh
+	}
+
+	if msg.ReplyTo != nil && msg.ReplyTo.SenderID == fe.BotID() {
+		return priorityHigh
+	}
+
+	if strings.Contains(msg.Text, fe.BotMention()) {
+		return priorityHigh
+	}
+
+	return priorityLow
+}
+
+func (s *scheduler) submit(chat ChatRef, priority int, run func()) *genJob {
+	job := &genJob{
+		chat:     ChatKey{ChatID: chat.ID, ThreadID: chat.ThreadID},
+		priority: priority,
+		run:      run,
+		done:     make(chan struct{}),
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.stopped {
+		close(job.done)
+		return job
+	}
+
+	queue := s.queues[priority][job.chat]
+	if len(queue) == 0 && !s.running[job.chat] {
+		s.rounds[priority] = append(s.rounds[priority], job.chat)
+	}
+	s.queues[priority][job.chat] = append(queue, job)
+
+	s.pending++
+	zayaMetrics.queueLength.Set(float64(s.pending))
+	s.cond.Signal()
+
+	return job
+}
+
+func (s *scheduler) run(chat ChatRef, priority int, run func()) {
+	<-s.submit(chat, priority, run).done
+}
+
+func (s *scheduler) next() (*genJob, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for !s.stopped {
+		job, ok := s.pop()
+		if ok {
+			return job, true
+		}
+
+		s.cond.Wait()
+	}
+
+	return nil, false
+}
+
+func (s *scheduler) pop() (*genJob, bool) {
+	for level := range s.rounds {
+		if len(s.rounds[level]) == 0 {
+			continue
+		}
+
+		key := s.rounds[level][0]
+		queue := s.queues[level][key]
+		job := queue[0]
+		if len(queue) > 1 {
+			s.queues[level][key] = queue[1:]
+		} else {
+			delete(s.queues[level], key)
+		}
+
+		for other := range s.rounds {
+			s.rounds[other] = slices.DeleteFunc(s.rounds[other], func(queued ChatKey) bool {
+				return queued == key
+			})
+		}
+		s.running[key] = true
+
+		s.pending--
+		zayaMetrics.queueLength.Set(float64(s.pending))
+		return job, true
+	}
+
+	return nil, false
+}
+
+func (s *scheduler) done(job *genJob) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.running, job.chat)
+	for level := range s.queues {
+		if len(s.queues[level][job.chat]) > 0 {
+			s.rounds[level] = append(s.rounds[level], job.chat)
+			s.cond.Signal()
+		}
+	}
+}
+
+func (s *scheduler) work() {
+	defer s.wg.Done()
+
+	for {
+		job, ok := s.next()
+		if !ok {
+			return
+		}
+
+		job.run()
+		s.done(job)
+		close(job.done)
+	}
+}
+
+func (s *scheduler) position(job *genJob) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	queue := s.queues[job.priority][job.chat]
+	idx := -1
+	for i, queued := range queue {
+		if queued == job {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return 0
+	}
+
+	ahead := idx
+	for level := 0; level < job.priority; level++ {
+		for _, queue := range s.queues[level] {
+			ahead += len(queue)
+		}
+	}
+
+	before := true
+	for _, key := range s.rounds[job.priority] {
+		if key == job.chat {
+			before = false
+			continue
+		}
+
+		served := idx
+		if before {
+			served++
+		}
+		ahead += min(len(s.queues[job.priority][key]), served)
+	}
+
+	return ahead
+}
+
+func (s *scheduler) stop() {
+	s.lock.Lock()
+	s.stopped = true
+	for level := range s.queues {
+		for _, queue := range s.queues[level] {
+			for _, job := range queue {
+				close(job.done)
+			}
+		}
+		s.queues[level] = make(map[ChatKey][]*genJob)
+		s.rounds[level] = nil
+	}
+	s.pending = 0
+	s.cond.Broadcast()
+	s.lock.Unlock()
+
+	s.wg.Wait()
+}
@@ -0,0 +1,109 @@
+package zaya
+
+import (
+	"github.com/stretchr/testify/require"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSchedulerOrder(t *testing.T) {
+	s := newScheduler(0)
+
+	fe := &testFrontend{}
+	busy := ChatRef{ID: -1}
+	quiet := ChatRef{ID: -2}
+	user := ChatRef{ID: 1, Private: true}
+
+	var jobs []*genJob
+	for i := 0; i < 3; i++ {
+		jobs = append(jobs, s.submit(busy, jobPriority(fe, &Message{Chat: busy}), func() {}))
+	}
+	jobs = append(jobs, s.submit(quiet, jobPriority(fe, &Message{Chat: quiet}), func() {}))
+	jobs = append(jobs, s.submit(user, jobPriority(fe, &Message{Chat: user}), func() {}))
+
+	require.Equal(t, 0, s.position(jobs[4]))
+	require.Equal(t, 1, s.position(jobs[0]))
+	require.Equal(t, 2, s.position(jobs[3]))
+	require.Equal(t, 4, s.position(jobs[2]))
+
+	expected := []*genJob{jobs[4], jobs[0], jobs[3], jobs[1], jobs[2]}
+	for _, want := range expected {
+		job, ok := s.next()
+		require.True(t, ok)
+		require.Same(t, want, job)
+		s.done(job)
+	}
+
+	require.Equal(t, 0, s.position(jobs[2]))
+
+	pending := s.submit(busy, priorityLow, func() {})
+	s.stop()
+	<-pending.done
+
+	stopped := s.submit(busy, priorityLow, func() {})
+	<-stopped.done
+}
+
+func TestSchedulerWorkers(t *testing.T) {
+	s := newScheduler(2)
+	defer s.stop()
+
+	var cnt atomic.Int64
+	for i := 0; i < 10; i++ {
+		s.run(ChatRef{ID: int64(i % 3)}, priorityLow, func() {
+			cnt.Add(1)
+		})
+	}
+
+	require.Equal(t, int64(10), cnt.Load())
+}
+
+func TestSchedulerBusyChat(t *testing.T) {
+	s := newScheduler(2)
+	defer s.stop()
+
+	busy := ChatRef{ID: -1}
+	release := make(chan struct{})
+	var running atomic.Int64
+	var overlap atomic.Bool
+
+	jobs := make([]*genJob, 0)
+	for i := 0; i < 4; i++ {
+		jobs = append(jobs, s.submit(busy, priorityLow, func() {
+			if running.Add(1) > 1 {
+				overlap.Store(true)
+			}
+			<-release
+			running.Add(-1)
+		}))
+	}
+
+	quiet := s.submit(ChatRef{ID: -2}, priorityLow, func() {})
+	select {
+	case <-quiet.done:
+	case <-time.After(time.Second):
+		t.Fatal("a busy chat took every worker")
+	}
+
+	close(release)
+	for _, job := range jobs {
+		<-job.done
+	}
+	require.False(t, overlap.Load())
+}
+
+func TestJobPriority(t *testing.T) {
+	fe := &testFrontend{}
+	group := ChatRef{ID: -1}
+
+	require.Equal(t, priorityLow, jobPriority(fe, &Message{Chat: group, Text: "hi all"}))
+	require.Equal(t, priorityHigh, jobPriority(fe, &Message{Chat: group, Text: "hi @test_bot"}))
+	require.Equal(t, priorityHigh, jobPriority(fe, &Message{Chat: group, Text: "why?",
+		ReplyTo: &Message{Chat: group, SenderID: fe.BotID()}}))
+	require.Equal(t, priorityLow, jobPriority(fe, &Message{Chat: group, Text: "why?",
+		ReplyTo: &Message{Chat: group, SenderID: 1}}))
+
+	require.Equal(t, defaultWorkers, AiConfig{}.workers())
+	require.Equal(t, 2, AiConfig{Workers: 2}.workers())
+}
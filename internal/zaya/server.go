@@ -0,0 +1,94 @@
+package zaya
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+type Runner interface {
+	Name() string
+	IsRunning() bool
+}
+
+type Server struct {
+	srv     *http.Server
+	db      *DB
+	runners []Runner
+	log     *zap.SugaredLogger
+}
+
+type healthStatus struct {
+	DB        bool            `json:"db"`
+	Poller    bool            `json:"poller"`
+	Frontends map[string]bool `json:"frontends"`
+}
+
+func NewServer(cfg HttpConfig, db *DB, runners ...Runner) *Server {
+	srv := &Server{
+		db:      db,
+		runners: runners,
+		log:     zap.L().Named("http").Sugar(),
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(zayaMetrics.registry, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/healthz", srv.healthz)
+
+	srv.srv = &http.Server{
+		Addr:              cfg.Listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	return srv
+}
+
+func (srv *Server) Start() {
+	go func() {
+		srv.log.Infow("starting http server", "addr", srv.srv.Addr)
+		err := srv.srv.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			srv.log.Error(err)
+		}
+		srv.log.Info("http server stopped")
+	}()
+}
+
+func (srv *Server) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := srv.srv.Shutdown(ctx)
+	if err != nil {
+		srv.log.Warn(err)
+	}
+}
+
+func (srv *Server) healthz(w http.ResponseWriter, _ *http.Request) {
+	status := healthStatus{
+		DB:        srv.db.Ping(),
+		Poller:    len(srv.runners) > 0,
+		Frontends: make(map[string]bool, len(srv.runners)),
+	}
+
+	for _, runner := range srv.runners {
+		isRunning := runner.IsRunning()
+		status.Frontends[runner.Name()] = isRunning
+		status.Poller = status.Poller && isRunning
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !status.DB || !status.Poller {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	err := json.NewEncoder(w).Encode(status)
+	if err != nil {
+		srv.log.Warn(err)
+	}
+}
@@ -0,0 +1,42 @@
+package zaya
+
+import (
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHealthz(t *testing.T) {
+	db := setupTestDB(t)
+	bot := &Bot{}
+	srv := NewServer(HttpConfig{}, db, bot)
+
+	rec := httptest.NewRecorder()
+	srv.srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.JSONEq(t, `{"db":true,"poller":false,"frontends":{"telegram":false}}`, rec.Body.String())
+
+	bot.isRunning.Store(true)
+	rec = httptest.NewRecorder()
+	srv.srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"db":true,"poller":true,"frontends":{"telegram":true}}`, rec.Body.String())
+}
+
+func TestMetrics(t *testing.T) {
+	db := setupTestDB(t)
+	srv := NewServer(HttpConfig{}, db)
+
+	zayaMetrics.sendErrors.WithLabelValues("typing").Inc()
+
+	rec := httptest.NewRecorder()
+	srv.srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	body := rec.Body.String()
+	require.True(t, strings.Contains(body, `zaya_telegram_send_errors_total{op="typing"}`))
+	require.True(t, strings.Contains(body, "zaya_generation_duration_seconds_bucket"))
+	require.True(t, strings.Contains(body, "zaya_chat_cache_size"))
+}
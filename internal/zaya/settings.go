@@ -0,0 +1,196 @@
+package zaya
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const maxSettingsRoles = 30
+
+var (
+	freqPresets    = []int{0, 5, 10, 30, 50, 100}
+	historyPresets = []int{10, 20, 30, 50}
+)
+
+type SettingsButton struct {
+	Text string
+	Data string
+}
+
+type SettingsPanel struct {
+	Text string
+	Rows [][]SettingsButton
+}
+
+type SettingsUpdate struct {
+	Panel   SettingsPanel
+	Notice  string
+	Message string
+}
+
+func presetButtons(prefix, format string, presets []int, current int) []SettingsButton {
+	buttons := make([]SettingsButton, 0, len(presets))
+	for _, preset := range presets {
+		text := fmt.Sprintf(format, preset)
+		if preset == current {
+			text = "• " + text
+		}
+
+		buttons = append(buttons, SettingsButton{Text: text, Data: fmt.Sprintf("%s:%d", prefix, preset)})
+	}
+
+	return buttons
+}
+
+func splitButtons(buttons []SettingsButton, size int) [][]SettingsButton {
+	rows := make([][]SettingsButton, 0, len(buttons)/size+1)
+	for len(buttons) > size {
+		rows = append(rows, buttons[:size])
+		buttons = buttons[size:]
+	}
+
+	if len(buttons) > 0 {
+		rows = append(rows, buttons)
+	}
+
+	return rows
+}
+
+func (core *Core) settingsText(chat ChatRef) string {
+	cfg := core.LoadConfig(chat)
+
+	lang := core.db.LoadChatConfig(chat.ID).Lang
+	if lang == "" {
+		lang = "any"
+	}
+
+	role := "custom"
+	found, ok := core.db.FindRoleByPrompt(chat.ID, cfg.Prompt)
+	if ok {
+		role = found.Name
+	}
+
+	model := "primary"
+	if core.ai.IsAltModel() {
+		model = "auxiliary"
+	}
+
+	return fmt.Sprintf(""+
+		"Settings:\n"+
+		"Frequency: %d%%\n"+
+		"History limit: %d\n"+
+		"Nickname: %s\n"+
+		"Role language: %s\n"+
+		"Role: %s\n"+
+		"Model: %s, temperature %.2g, up to %d tokens per reply, %d context tokens",
+		cfg.Freq, cfg.MaxHistory, strings.Join(configPersona(cfg).aliases(), ", "),
+		lang, role, model, core.ai.temp, core.ai.maxTok, core.ai.maxCtx+core.ai.maxTok)
+}
+
+func (core *Core) SettingsPanel(chat ChatRef, page string) SettingsPanel {
+	back := []SettingsButton{{Text: "« Back", Data: "page:main"}}
+
+	switch page {
+	case "lang":
+		buttons := []SettingsButton{{Text: "Any", Data: "lang:"}}
+		for _, lang := range core.db.LoadRoleLangs(chat.ID) {
+			buttons = append(buttons, SettingsButton{Text: lang, Data: "lang:" + lang})
+		}
+
+		rows := append(splitButtons(buttons, 4), back)
+		return SettingsPanel{Text: "Show roles in which language?", Rows: rows}
+	case "role":
+		lang := core.db.LoadChatConfig(chat.ID).Lang
+
+		buttons := make([]SettingsButton, 0)
+		for _, role := range core.db.LoadAllRoleNames(chat.ID) {
+			if lang != "" && role.Lang != lang {
+				continue
+			}
+
+			buttons = append(buttons, SettingsButton{Text: role.Name, Data: fmt.Sprintf("role:%d", role.ID)})
+			if len(buttons) == maxSettingsRoles {
+				break
+			}
+		}
+
+		rows := append(splitButtons(buttons, 3), back)
+		return SettingsPanel{Text: "Select a role, the chat will be restarted.", Rows: rows}
+	}
+
+	cfg := core.LoadConfig(chat)
+	return SettingsPanel{
+		Text: core.settingsText(chat),
+		Rows: [][]SettingsButton{
+			presetButtons("freq", "%d%%", freqPresets, cfg.Freq),
+			presetButtons("hist", "%d msgs", historyPresets, cfg.MaxHistory),
+			{
+				{Text: "Nickname", Data: "nick"},
+				{Text: "Language", Data: "page:lang"},
+				{Text: "Role", Data: "page:role"},
+			},
+			{{Text: "Close", Data: "close"}},
+		},
+	}
+}
+
+func (core *Core) ApplySetting(fe Frontend, msg *Message, data string) SettingsUpdate {
+	chat := msg.Chat
+	if !isChatAdmin(fe, msg) {
+		return SettingsUpdate{
+			Panel:  core.SettingsPanel(chat, "main"),
+			Notice: "Only chat admins can change the settings.",
+		}
+	}
+
+	name, value, _ := strings.Cut(data, ":")
+	switch name {
+	case "page":
+		return SettingsUpdate{Panel: core.SettingsPanel(chat, value)}
+	case "close":
+		return SettingsUpdate{Panel: SettingsPanel{Text: "Settings closed."}}
+	case "freq":
+		freq, err := strconv.Atoi(value)
+		if err == nil && freq >= 0 && freq <= 100 {
+			core.SetFreq(chat, freq)
+			return SettingsUpdate{Panel: core.SettingsPanel(chat, "main"), Notice: "Frequency changed."}
+		}
+	case "hist":
+		limit, err := strconv.Atoi(value)
+		if err == nil && limit > 0 {
+			core.SetMaxHistory(chat, limit)
+			return SettingsUpdate{Panel: core.SettingsPanel(chat, "main"), Notice: "History limit changed."}
+		}
+	case "lang":
+		if value == "" || slices.Contains(core.db.LoadRoleLangs(chat.ID), value) {
+			core.db.SetLang(chat.ID, value)
+			return SettingsUpdate{Panel: core.SettingsPanel(chat, "role"), Notice: "Language changed."}
+		}
+	case "role":
+		id, err := strconv.Atoi(value)
+		if err == nil {
+			role, ok := core.SetRole(chat, uint(id))
+			if ok {
+				return SettingsUpdate{
+					Panel:  core.SettingsPanel(chat, "main"),
+					Notice: fmt.Sprintf("Now I'm acting as %s.", role.Name),
+				}
+			}
+		}
+	case "nick":
+		question, _ := core.StartFlow(msg, "set_nickname")
+		return SettingsUpdate{Panel: core.SettingsPanel(chat, "main"), Message: question}
+	}
+
+	return SettingsUpdate{Panel: core.SettingsPanel(chat, "main"), Notice: "Can't change this setting."}
+}
+
+func (core *Core) Settings(fe Frontend, msg *Message) (SettingsPanel, bool) {
+	if !isChatAdmin(fe, msg) {
+		return SettingsPanel{Text: "Only chat admins can change the settings."}, false
+	}
+
+	return core.SettingsPanel(msg.Chat, "main"), true
+}
@@ -0,0 +1,98 @@
+package zaya
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func findButton(panel SettingsPanel, text string) (SettingsButton, bool) {
+	for _, row := range panel.Rows {
+		for _, button := range row {
+			if button.Text == text {
+				return button, true
+			}
+		}
+	}
+
+	return SettingsButton{}, false
+}
+
+func TestSettingsPanel(t *testing.T) {
+	core, _ := setupTestCore(t)
+	fe := &testFrontend{}
+	chat := ChatRef{ID: 1, Private: true}
+	msg := &Message{Chat: chat, SenderID: 1}
+
+	core.db.SaveRole(chat.ID, "ru", "Pirate")
+	core.SetPrompt(chat, "something else")
+
+	panel, ok := core.Settings(fe, msg)
+	require.True(t, ok)
+	require.Contains(t, panel.Text, "Frequency: 10%")
+	require.Contains(t, panel.Text, "Role: custom")
+	_, ok = findButton(panel, "• 10%")
+	require.True(t, ok)
+
+	update := core.ApplySetting(fe, msg, "freq:50")
+	require.Equal(t, "Frequency changed.", update.Notice)
+	require.Equal(t, 50, core.LoadConfig(chat).Freq)
+	_, ok = findButton(update.Panel, "• 50%")
+	require.True(t, ok)
+
+	core.ApplySetting(fe, msg, "freq:0")
+	require.Equal(t, 0, core.LoadConfig(chat).Freq)
+
+	core.ApplySetting(fe, msg, "hist:30")
+	require.Equal(t, 30, core.LoadConfig(chat).MaxHistory)
+
+	update = core.ApplySetting(fe, msg, "freq:500")
+	require.Equal(t, "Can't change this setting.", update.Notice)
+
+	update = core.ApplySetting(fe, msg, "page:lang")
+	_, ok = findButton(update.Panel, "ru")
+	require.True(t, ok)
+
+	update = core.ApplySetting(fe, msg, "lang:de")
+	require.Equal(t, "Can't change this setting.", update.Notice)
+
+	update = core.ApplySetting(fe, msg, "lang:ru")
+	require.Equal(t, "ru", core.db.LoadChatConfig(chat.ID).Lang)
+	button, ok := findButton(update.Panel, "Pirate")
+	require.True(t, ok)
+
+	update = core.ApplySetting(fe, msg, button.Data)
+	require.Equal(t, "Now I'm acting as Pirate.", update.Notice)
+	require.Contains(t, update.Panel.Text, "Role: Pirate")
+	require.Contains(t, update.Panel.Text, "Role language: ru")
+
+	update = core.ApplySetting(fe, msg, "nick")
+	require.Contains(t, update.Message, "How should I be called?")
+	_, err := core.ReadMessage(fe, &Message{Chat: chat, SenderID: 1, Text: "zaya, зая"})
+	require.NoError(t, err)
+	require.Equal(t, "Nickname changed.", lastSent(fe))
+
+	update = core.ApplySetting(fe, msg, "close")
+	require.Equal(t, "Settings closed.", update.Panel.Text)
+	require.Empty(t, update.Panel.Rows)
+}
+
+func TestSettingsAdmins(t *testing.T) {
+	core, _ := setupTestCore(t)
+	fe := &testFrontend{admins: map[int64]bool{7: true}}
+	group := ChatRef{ID: -1}
+
+	panel, ok := core.Settings(fe, &Message{Chat: group, SenderID: 5})
+	require.False(t, ok)
+	require.Equal(t, "Only chat admins can change the settings.", panel.Text)
+
+	_, ok = core.Settings(fe, &Message{Chat: group, SenderID: 7})
+	require.True(t, ok)
+
+	update := core.ApplySetting(fe, &Message{Chat: group, SenderID: 5}, "freq:100")
+	require.Equal(t, "Only chat admins can change the settings.", update.Notice)
+	require.NotEqual(t, 100, core.LoadConfig(group).Freq)
+
+	update = core.ApplySetting(fe, &Message{Chat: group, SenderID: 7}, "freq:100")
+	require.Equal(t, "Frequency changed.", update.Notice)
+	require.Equal(t, 100, core.LoadConfig(group).Freq)
+}
@@ -0,0 +1,171 @@
+package zaya
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxBufferedMessages  = 500
+	bufferedMessageTTL   = 24 * time.Hour
+	defaultSummaryCount  = 50
+	maxSummaryTranscript = 12000
+)
+
+const summaryPrompt = "" +
+	"You summarize group chat discussions for people who missed them. " +
+	"Write a short recap as a few bullet points: the main topics, decisions and open questions. " +
+	"Mention who said what when it matters. Write in the language of the discussion."
+
+type bufferedMessage struct {
+	time   time.Time
+	sender string
+	text   string
+}
+
+func (core *Core) bufferMessage(chat ChatRef, sender, text string) {
+	if chat.Private || text == "" {
+		return
+	}
+
+	now := time.Now()
+
+	core.recentLock.Lock()
+	defer core.recentLock.Unlock()
+
+	messages := core.recent[chat.Key()]
+
+	skip := 0
+	for skip < len(messages) && now.Sub(messages[skip].time) > bufferedMessageTTL {
+		skip++
+	}
+	if len(messages)-skip >= maxBufferedMessages {
+		skip = len(messages) - maxBufferedMessages + 1
+	}
+
+	messages = append(messages[skip:], bufferedMessage{
+		time:   now,
+		sender: sender,
+		text:   text,
+	})
+	core.recent[chat.Key()] = messages
+}
+
+func (core *Core) recentMessages(chat ChatRef) []bufferedMessage {
+	core.recentLock.Lock()
+	defer core.recentLock.Unlock()
+
+	return append([]bufferedMessage(nil), core.recent[chat.Key()]...)
+}
+
+func selectMessages(messages []bufferedMessage, arg string, now time.Time) ([]bufferedMessage, bool) {
+	since := now.Add(-bufferedMessageTTL)
+
+	switch {
+	case arg == "":
+		return messages[max(len(messages)-defaultSummaryCount, 0):], true
+	case arg == "today":
+		since = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	default:
+		n, err := strconv.Atoi(arg)
+		if err == nil {
+			if n < 1 || n > maxBufferedMessages {
+				return nil, false
+			}
+
+			return messages[max(len(messages)-n, 0):], true
+		}
+
+		dur, err := time.ParseDuration(arg)
+		if err != nil || dur <= 0 || dur > bufferedMessageTTL {
+			return nil, false
+		}
+
+		since = now.Add(-dur)
+	}
+
+	for i, msg := range messages {
+		if !msg.time.Before(since) {
+			return messages[i:], true
+		}
+	}
+
+	return nil, true
+}
+
+func summaryTranscript(messages []bufferedMessage, loc *time.Location) string {
+	lines := make([]string, 0, len(messages))
+	size := 0
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg := messages[i]
+		line := fmt.Sprintf("[%s] %s: %s", msg.time.In(loc).Format("15:04"), msg.sender, msg.text)
+		if size+len(line) > maxSummaryTranscript {
+			break
+		}
+
+		lines = append(lines, line)
+		size += len(line) + 1
+	}
+
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (core *Core) Summarize(fe Frontend, msg *Message, arg string) string {
+	const usage = "" +
+		"Example usage: summarize 100\n" +
+		"The argument may be a number of messages, a time window like 1h or 30m, or today."
+
+	if msg.Chat.Private {
+		return "This command works only in group chats."
+	}
+
+	loc := core.Location(msg.Chat)
+	messages, ok := selectMessages(core.recentMessages(msg.Chat), strings.ToLower(arg), time.Now().In(loc))
+	if !ok {
+		return usage
+	}
+
+	if len(messages) == 0 {
+		return "There is nothing to summarize yet."
+	}
+
+	err := fe.Typing(msg.Chat)
+	if err != nil {
+		core.log.Warnw(err.Error(), "chat_id", msg.Chat.ID)
+	}
+
+	notice, ok := core.checkQuota(msg.Chat, msg.SenderID, time.Now())
+	if !ok {
+		zayaMetrics.quotaRejections.Inc()
+		return notice
+	}
+
+	transcript := summaryTranscript(messages, loc)
+
+	var summary AIReply
+	ok = false
+	core.scheduler.run(msg.Chat, priorityLow, func() {
+		summary, ok = core.ai.Complete(msg.Chat.Key(), summaryPrompt, transcript, true)
+	})
+	core.chargeQuota(msg.Chat, msg.SenderID, summary.CtxLen+summary.ReplyLen)
+	if !ok {
+		return "Can't summarize the discussion right now, try again later."
+	}
+
+	opts := SendOptions{
+		Markdown: true,
+		Label:    fmt.Sprintf("Summary of %d messages", len(messages)),
+	}
+	_, err = fe.Send(msg.Chat, msg, summary.Text, opts)
+	if err != nil {
+		core.log.Warnw(err.Error(), "chat_id", msg.Chat.ID)
+	}
+
+	return ""
+}
@@ -0,0 +1,79 @@
+package zaya
+
+import (
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSelectMessages(t *testing.T) {
+	now := time.Date(2030, 1, 31, 12, 0, 0, 0, time.UTC)
+	messages := []bufferedMessage{
+		{time: now.Add(-20 * time.Hour), text: "yesterday"},
+		{time: now.Add(-2 * time.Hour), text: "morning"},
+		{time: now.Add(-30 * time.Minute), text: "noon"},
+	}
+
+	selected, ok := selectMessages(messages, "", now)
+	require.True(t, ok)
+	require.Len(t, selected, 3)
+
+	selected, ok = selectMessages(messages, "2", now)
+	require.True(t, ok)
+	require.Equal(t, "morning", selected[0].text)
+
+	selected, ok = selectMessages(messages, "1h", now)
+	require.True(t, ok)
+	require.Len(t, selected, 1)
+
+	selected, ok = selectMessages(messages, "today", now)
+	require.True(t, ok)
+	require.Len(t, selected, 2)
+
+	for _, arg := range []string{"0", "1000", "-1h", "48h", "week"} {
+		_, ok = selectMessages(messages, arg, now)
+		require.False(t, ok, arg)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	core, llm := setupTestCore(t)
+	altLlm := &testLLM{}
+	core.ai.altLlm = altLlm
+	fe := &testFrontend{}
+	group := ChatRef{ID: -1}
+	core.db.SetFreq(group.ID, 0)
+
+	text, _ := core.RunCommand(fe, &Message{Chat: group}, "summarize", "")
+	require.Equal(t, "There is nothing to summarize yet.", text)
+
+	for i := 0; i < maxBufferedMessages+10; i++ {
+		_, err := core.ReadMessage(fe, &Message{Chat: group, SenderName: "alice", Text: "message " + strconv.Itoa(i)})
+		require.NoError(t, err)
+	}
+	_, err := core.ReadMessage(fe, &Message{Chat: group, Text: "hey @test_bot"})
+	require.NoError(t, err)
+	require.Len(t, core.recentMessages(group), maxBufferedMessages)
+
+	llm.lastMessages = nil
+	text, _ = core.RunCommand(fe, &Message{Chat: group}, "summarize", "3")
+	require.Empty(t, text)
+	require.Nil(t, llm.lastMessages)
+
+	require.Equal(t, summaryPrompt, altLlm.lastMessages[0].Parts[0].(llms.TextContent).Text)
+	transcript := altLlm.lastMessages[1].Parts[0].(llms.TextContent).Text
+	lines := strings.Split(transcript, "\n")
+	require.Len(t, lines, 3)
+	require.True(t, strings.HasSuffix(lines[0], "alice: message 509"), lines[0])
+	require.True(t, strings.HasSuffix(lines[1], "anonymous: hey @test_bot"), lines[1])
+	require.True(t, strings.HasSuffix(lines[2], "test: re: hey"), lines[2])
+
+	sent := fe.sent[len(fe.sent)-1].Text
+	require.True(t, strings.HasPrefix(sent, "Summary of 3 messages:\nre: "), sent)
+
+	text, _ = core.RunCommand(fe, &Message{Chat: ChatRef{ID: 1, Private: true}}, "summarize", "")
+	require.Equal(t, "This command works only in group chats.", text)
+}
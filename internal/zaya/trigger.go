@@ -0,0 +1,138 @@
+package zaya
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+const (
+	maxAliases     = 10
+	maxAliasLength = 32
+	minStemLength  = 2
+)
+
+var wordEndings = []string{
+	"ями", "ами", "ого", "его", "ому", "ему", "ыми", "ими",
+	"ой", "ей", "ий", "ый", "ая", "яя", "ое", "ее", "ую", "юю",
+	"ых", "их", "ом", "ем", "ах", "ях", "ов", "ев", "es",
+	"а", "я", "о", "е", "у", "ю", "ы", "и", "ь", "й", "s",
+}
+
+func splitWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func splitAliases(text string) []string {
+	aliases := make([]string, 0)
+	for _, alias := range strings.Split(text, ",") {
+		alias = strings.TrimSpace(alias)
+		if alias != "" {
+			aliases = append(aliases, alias)
+		}
+	}
+
+	return aliases
+}
+
+func parseAliases(text string) ([]string, bool) {
+	aliases := splitAliases(strings.ToLower(text))
+	if len(aliases) == 0 || len(aliases) > maxAliases {
+		return nil, false
+	}
+
+	for _, alias := range aliases {
+		if len([]rune(alias)) > maxAliasLength || len(splitWords(alias)) == 0 {
+			return nil, false
+		}
+	}
+
+	return aliases, true
+}
+
+func wordStem(word string) string {
+	runes := []rune(word)
+	for _, ending := range wordEndings {
+		n := len([]rune(ending))
+		if len(runes)-n >= minStemLength && strings.HasSuffix(word, ending) {
+			return string(runes[:len(runes)-n])
+		}
+	}
+
+	return word
+}
+
+func wordMatches(word, alias string, exact bool) bool {
+	if word == alias {
+		return true
+	}
+
+	if exact || len([]rune(alias)) <= minStemLength {
+		return false
+	}
+
+	stem := wordStem(alias)
+	return wordStem(word) == stem && (word != stem || alias == stem)
+}
+
+func matchAlias(words []string, alias string) (string, bool) {
+	exact := len(alias) > 1 && strings.HasPrefix(alias, `"`) && strings.HasSuffix(alias, `"`)
+	aliasWords := splitWords(alias)
+	if len(aliasWords) == 0 {
+		return "", false
+	}
+
+	for i := 0; i+len(aliasWords) <= len(words); i++ {
+		matched := true
+		for j, aliasWord := range aliasWords {
+			if !wordMatches(words[i+j], aliasWord, exact) {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			return strings.Join(words[i:i+len(aliasWords)], " "), true
+		}
+	}
+
+	return "", false
+}
+
+func matchPersona(personas []Persona, text string) (Persona, string, string, bool) {
+	words := splitWords(text)
+	for _, persona := range personas {
+		for _, alias := range persona.aliases() {
+			word, ok := matchAlias(words, alias)
+			if ok {
+				return persona, alias, word, true
+			}
+		}
+	}
+
+	return Persona{}, "", "", false
+}
+
+func (core *Core) TestTrigger(fe Frontend, chat ChatRef, text string) string {
+	if strings.TrimSpace(text) == "" {
+		return "Example usage: test_trigger hi zaya, how are you?"
+	}
+
+	personas := core.loadPersonas(chat, core.LoadConfig(chat))
+	persona, alias, word, ok := matchPersona(personas, text)
+	if ok {
+		if persona.Name != "" {
+			return fmt.Sprintf("Yes, %s would answer: %q matches the alias %s.", persona.Name, word, alias)
+		}
+
+		return fmt.Sprintf("Yes, %q matches the alias %s.", word, alias)
+	}
+
+	if strings.Contains(text, fe.BotMention()) {
+		return "Yes, the phrase mentions me."
+	}
+
+	return "No, the phrase doesn't contain any of my aliases."
+}
@@ -0,0 +1,81 @@
+package zaya
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestMatchAlias(t *testing.T) {
+	tests := []struct {
+		alias string
+		text  string
+		match bool
+	}{
+		{"зая", "Привет, зая!", true},
+		{"зая", "скажи, ЗАЯ", true},
+		{"зая", "передай заю привет", true},
+		{"зая", "поговори с заей", true},
+		{"зая", "оставил заявку", false},
+		{"зая", "узаять", false},
+		{"зая", "за домом", false},
+		{"бот", "спроси бота", true},
+		{`"бот"`, "спроси бота", false},
+		{`"бот"`, "эй, бот", true},
+		{"llama", "ask the llamas", true},
+		{"llama", "llam", false},
+		{"mister zaya", "hi mister zaya", true},
+		{"mister zaya", "mister and zaya", false},
+		{"zaya", "@zaya_bot hi", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.alias+" "+tt.text, func(t *testing.T) {
+			_, ok := matchAlias(splitWords(tt.text), tt.alias)
+			require.Equal(t, tt.match, ok)
+		})
+	}
+}
+
+func TestParseAliases(t *testing.T) {
+	aliases, ok := parseAliases(` Зая, zaya ,, "Bot" `)
+	require.True(t, ok)
+	require.Equal(t, []string{"зая", "zaya", `"bot"`}, aliases)
+
+	_, ok = parseAliases(" , ")
+	require.False(t, ok)
+
+	_, ok = parseAliases("!!!")
+	require.False(t, ok)
+}
+
+func TestSetAliases(t *testing.T) {
+	core, _ := setupTestCore(t)
+	fe := &testFrontend{}
+	group := ChatRef{ID: -1}
+	msg := &Message{Chat: group}
+	core.db.SetFreq(group.ID, 0)
+
+	text, _ := core.RunCommand(fe, msg, "set_nickname", "зая, Заечка")
+	require.Equal(t, "Nickname changed.", text)
+
+	text, _ = core.RunCommand(fe, msg, "get_nickname", "")
+	require.Equal(t, "You can call me зая, заечка.", text)
+
+	_, reply, _ := core.ShouldReplyTo(fe, &Message{Chat: group, Text: "заечку спроси"})
+	require.True(t, reply)
+
+	_, reply, _ = core.ShouldReplyTo(fe, &Message{Chat: group, Text: "test"})
+	require.False(t, reply)
+
+	text, _ = core.RunCommand(fe, msg, "test_trigger", "позови заю")
+	require.Equal(t, `Yes, "заю" matches the alias зая.`, text)
+
+	text, _ = core.RunCommand(fe, msg, "test_trigger", "новая заявка")
+	require.Equal(t, "No, the phrase doesn't contain any of my aliases.", text)
+
+	core.db.SaveRole(group.ID, "en", "Zaya")
+	roles := core.db.LoadChatRoleNames(group.ID)
+	_, ok := core.SetRole(group, roles[0].ID)
+	require.True(t, ok)
+	require.Empty(t, core.LoadConfig(group).Aliases)
+}
@@ -0,0 +1,56 @@
+package zaya
+
+import (
+	"strings"
+	"text/template"
+)
+
+type turnContext struct {
+	Text        string
+	Media       string
+	Quote       string
+	QuoteAuthor string
+	Forward     string
+}
+
+func truncateText(text string, limit int) string {
+	runes := []rune(text)
+	if limit <= 0 || len(runes) <= limit {
+		return text
+	}
+
+	return strings.TrimSpace(string(runes[:limit])) + "…"
+}
+
+func parseTurnTemplate(text string) (*template.Template, error) {
+	return template.New("turn").Option("missingkey=error").Parse(text)
+}
+
+func (core *Core) userTurn(fe Frontend, msg *Message) string {
+	data := turnContext{
+		Text:    strings.TrimSpace(strings.ReplaceAll(msg.Text, fe.BotMention(), "")),
+		Media:   msg.Media,
+		Forward: truncateText(msg.ForwardFrom, 100),
+	}
+
+	if msg.ReplyTo != nil && msg.ReplyTo.SenderID != fe.BotID() {
+		data.Quote = strings.TrimSpace(msg.ReplyTo.Text)
+		if data.Text != "" {
+			data.Quote = truncateText(data.Quote, core.turn.MaxQuote)
+		}
+		data.QuoteAuthor = msg.ReplyTo.SenderName
+	}
+
+	if data.Quote == "" && data.Forward == "" && data.Media == "" {
+		return data.Text
+	}
+
+	var turn strings.Builder
+	err := core.turnTmpl.Execute(&turn, data)
+	if err != nil {
+		core.log.Warnw(err.Error(), "chat_id", msg.Chat.ID)
+		return data.Text
+	}
+
+	return strings.TrimSpace(turn.String())
+}
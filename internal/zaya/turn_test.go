@@ -0,0 +1,92 @@
+package zaya
+
+import (
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+	"strings"
+	"testing"
+)
+
+func TestUserTurn(t *testing.T) {
+	core, _ := setupTestCore(t)
+	fe := &testFrontend{}
+	chat := ChatRef{ID: -1}
+
+	tests := []struct {
+		name string
+		msg  *Message
+		turn string
+	}{
+		{
+			name: "Plain text",
+			msg:  &Message{Chat: chat, Text: "@test_bot hello"},
+			turn: "hello",
+		},
+		{
+			name: "Quote",
+			msg: &Message{Chat: chat, Text: "what do you think?",
+				ReplyTo: &Message{SenderID: 5, SenderName: "bob", Text: "cats are better"}},
+			turn: "> bob: cats are better\nwhat do you think?",
+		},
+		{
+			name: "Caption",
+			msg: &Message{Chat: chat, Text: "look",
+				ReplyTo: &Message{SenderID: 5, Text: "my dog", Media: "photo"}},
+			turn: "> my dog\nlook",
+		},
+		{
+			name: "Captioned photo",
+			msg:  &Message{Chat: chat, Text: "@test_bot what breed is it?", Media: "photo"},
+			turn: "[photo] what breed is it?",
+		},
+		{
+			name: "Mention only",
+			msg: &Message{Chat: chat, Text: "@test_bot",
+				ReplyTo: &Message{SenderID: 5, SenderName: "bob", Text: strings.Repeat("b", 400)}},
+			turn: "> bob: " + strings.Repeat("b", 400),
+		},
+		{
+			name: "Reply to bot",
+			msg: &Message{Chat: chat, Text: "why?",
+				ReplyTo: &Message{SenderID: 100, Text: "because"}},
+			turn: "why?",
+		},
+		{
+			name: "Forward",
+			msg:  &Message{Chat: chat, Text: "news", ForwardFrom: "@channel"},
+			turn: "Forwarded from @channel:\nnews",
+		},
+		{
+			name: "Long quote",
+			msg: &Message{Chat: chat, Text: "tl;dr",
+				ReplyTo: &Message{SenderID: 5, Text: strings.Repeat("a", 400)}},
+			turn: "> " + strings.Repeat("a", 300) + "…\ntl;dr",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.turn, core.userTurn(fe, tt.msg))
+		})
+	}
+}
+
+func TestTurnTemplate(t *testing.T) {
+	ai, llm := setupTestAI(t)
+	db := setupTestDB(t)
+	core := NewCore(ai, db, Config{Turn: TurnConfig{
+		Template: "{{.Text}} (re {{.QuoteAuthor}}: {{.Quote}})",
+		MaxQuote: 5,
+	}})
+	fe := &testFrontend{}
+
+	msg := &Message{Chat: ChatRef{ID: -1}, SenderID: 6, Text: "@test_bot is it true?",
+		ReplyTo: &Message{SenderID: 5, SenderName: "bob", Text: "the earth is flat"}}
+	replied, err := core.ReadMessage(fe, msg)
+	require.NoError(t, err)
+	require.True(t, replied)
+	require.Equal(t, "is it true? (re bob: the e…)", llm.lastMessages[len(llm.lastMessages)-1].Parts[0].(llms.TextContent).Text)
+
+	require.Error(t, TurnConfig{Template: "{{.Text"}.validate())
+	require.NoError(t, TurnConfig{}.validate())
+}
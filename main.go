@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"go.uber.org/zap"
 	"neuralzaya/internal/zaya"
 	"os"
@@ -8,6 +9,9 @@ import (
 )
 
 func main() {
+	consoleMode := flag.Bool("console", false, "chat in the terminal instead of Telegram")
+	flag.Parse()
+
 	cfg, err := zaya.LoadConfig()
 	if err != nil {
 		panic(err)
@@ -37,6 +41,7 @@ func main() {
 		Freq:     cfg.DefaultCfg.Freq,
 		Nickname: cfg.DefaultCfg.Nickname,
 		Prompt:   cfg.DefaultCfg.Prompt,
+		Timezone: cfg.DefaultCfg.Timezone,
 	}
 	db, ok := zaya.LoadDatabase(cfg.DBPath, defaultChatConfig)
 	if !ok {
@@ -63,15 +68,55 @@ func main() {
 		db.SaveMessages(allMessages)
 	}()
 
-	bot, ok := zaya.NewBot(cfg, ai, db)
-	if !ok {
-		logger.Panic("can't create bot")
+	core := zaya.NewCore(ai, db, cfg)
+	core.Start()
+	defer core.Stop()
+
+	if *consoleMode {
+		console, ok := zaya.NewConsole(core, os.Stdin, os.Stdout)
+		if !ok {
+			logger.Panic("can't create console")
+		}
+
+		console.Run()
+		return
+	}
+
+	runners := make([]zaya.Runner, 0, 2)
+
+	if cfg.TgToken != "" {
+		bot, ok := zaya.NewBot(cfg, core)
+		if !ok {
+			logger.Panic("can't create bot")
+		}
+
+		bot.Start()
+		defer bot.Stop()
+		runners = append(runners, bot)
+	}
+
+	if cfg.Matrix.IsEnabled() {
+		matrix, ok := zaya.NewMatrix(cfg.Matrix, core)
+		if !ok {
+			logger.Panic("can't create matrix client")
+		}
+
+		matrix.Start()
+		defer matrix.Stop()
+		runners = append(runners, matrix)
 	}
 
-	bot.Start()
-	defer bot.Stop()
+	if len(runners) == 0 {
+		logger.Panic("telegram token or matrix homeserver is required")
+	}
+
+	if cfg.Http.Listen != "" {
+		srv := zaya.NewServer(cfg.Http, db, runners...)
+		srv.Start()
+		defer srv.Stop()
+	}
 
-	quit := make(chan os.Signal)
+	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt)
 	<-quit
 }